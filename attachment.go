@@ -0,0 +1,85 @@
+package gomua
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// An Attachment is a file sent along with a Message.
+type Attachment struct {
+	Name      string
+	MediaType string
+	Size      int64
+	part      *Part
+}
+
+// Reader returns a reader of the decoded content of the Attachment.
+func (a *Attachment) Reader() io.Reader {
+	return bytes.NewReader(a.part.Body)
+}
+
+// String describes the Attachment on one line.
+func (a *Attachment) String() string {
+	return fmt.Sprintf("%s (%s, %d bytes)", a.Name, a.MediaType, a.Size)
+}
+
+// Attachments returns every file attached to the Message, in the order they appear.
+// A part is an attachment if the sender marked it as one, or if it is a named
+// part that is not displayed as the text of the Message.
+func (m *Message) Attachments() ([]*Attachment, error) {
+	root, err := m.MIME()
+	if root == nil {
+		return nil, err
+	}
+
+	text := make(map[*Part]bool)
+	for _, p := range root.TextParts() {
+		text[p] = true
+	}
+
+	var atts []*Attachment
+	root.Walk(func(p *Part) {
+		if p.IsMultipart() || text[p] {
+			return
+		}
+		name := partFilename(p)
+		if !p.IsAttachment() && name == "" {
+			return
+		}
+		if name == "" {
+			name = fmt.Sprintf("attachment-%d", len(atts)+1)
+		}
+		atts = append(atts, &Attachment{
+			Name:      name,
+			MediaType: p.MediaType,
+			Size:      int64(len(p.Body)),
+			part:      p,
+		})
+	})
+	return atts, err
+}
+
+// partFilename returns the file name a Part was sent with, safe to use as the last element of a path.
+// Content-Disposition filenames are preferred over the older Content-Type name parameter.
+func partFilename(p *Part) string {
+	name := p.DispositionParams["filename"]
+	if name == "" {
+		name = p.Params["name"]
+	}
+	if name == "" {
+		return ""
+	}
+	// many clients use RFC 2047 encoded-words here instead of RFC 2231
+	if d, err := wordDecoder.DecodeHeader(name); err == nil {
+		name = d
+	}
+
+	name = filepath.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,248 @@
+// Package arc implements an adaptive replacement cache.Cache.
+package arc
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/frenata/gomua/cache"
+)
+
+var _ cache.Cache = (*Cache)(nil)
+
+// The four lists of an adaptive replacement cache. t1 and t2 hold the cached elements,
+// used once and more than once recently, while b1 and b2 are ghosts: the keys recently
+// evicted from t1 and t2, kept to learn which of the two deserves more room.
+const (
+	t1 = iota
+	t2
+	b1
+	b2
+)
+
+// Cache is a cache.Cache that balances evicting the least recently used element against evicting the
+// least frequently used one, adapting to the workload as described by Megiddo and Modha in
+// "ARC: A Self-Tuning, Low Overhead Replacement Cache". Every operation is O(1).
+//
+// Weighted elements are handled by measuring the lists, and the target size of t1, by weight instead of length.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	p        int // target weight of t1
+	weigh    cache.Weigher
+	onEvict  cache.EvictFunc
+	items    map[interface{}]*list.Element
+	lists    [4]*list.List // of *entry, most recently used first
+	weights  [4]int
+}
+
+// An entry is one element of the Cache, or the ghost of one.
+type entry struct {
+	key, value interface{}
+	weight     int
+	list       int
+}
+
+// New returns an empty Cache holding at most capacity elements.
+// If onEvict is not nil, it is called with every element evicted.
+func New(capacity int, onEvict cache.EvictFunc) *Cache {
+	return NewWeighted(capacity, nil, onEvict)
+}
+
+// NewWeighted returns an empty Cache holding elements up to a total weight of capacity, as given by weigh.
+// If onEvict is not nil, it is called with every element evicted.
+func NewWeighted(capacity int, weigh cache.Weigher, onEvict cache.EvictFunc) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c := &Cache{
+		capacity: capacity,
+		weigh:    cache.WeighOrCount(weigh),
+		onEvict:  onEvict,
+		items:    make(map[interface{}]*list.Element),
+	}
+	for i := range c.lists {
+		c.lists[i] = list.New()
+	}
+	return c
+}
+
+// Add an element to the cache, replacing any element with the same key.
+// An element heavier than the capacity is not added.
+func (c *Cache) Add(key, value interface{}) {
+	w := c.weigh(key, value)
+
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if w > c.capacity {
+		if ok {
+			c.remove(el)
+		}
+		c.mu.Unlock()
+		return
+	}
+
+	var evicted []*entry
+	switch {
+	case ok && c.resident(el):
+		e := el.Value.(*entry)
+		c.weights[e.list] += w - e.weight
+		e.value, e.weight = value, w
+		c.move(el, t2)
+		evicted = c.replace(0, false)
+	case ok:
+		// a ghost hit: the list it was evicted from should have been larger
+		e := c.remove(el)
+		if e.list == b1 {
+			c.p = min(c.capacity, c.p+delta(w, c.weights[b2], c.weights[b1]))
+		} else {
+			c.p = max(0, c.p-delta(w, c.weights[b1], c.weights[b2]))
+		}
+		evicted = c.replace(w, e.list == b2)
+		c.push(&entry{key: key, value: value, weight: w}, t2)
+	default:
+		for c.weights[t1]+c.weights[b1]+w > c.capacity && c.lists[b1].Len() > 0 {
+			c.remove(c.lists[b1].Back())
+		}
+		for c.total()+w > 2*c.capacity && c.lists[b2].Len() > 0 {
+			c.remove(c.lists[b2].Back())
+		}
+		evicted = c.replace(w, false)
+		c.push(&entry{key: key, value: value, weight: w}, t1)
+	}
+	c.trimGhosts()
+	c.mu.Unlock()
+
+	if c.onEvict != nil {
+		for _, e := range evicted {
+			c.onEvict(e.key, e.value)
+		}
+	}
+}
+
+// Delete an element from the cache
+func (c *Cache) Delete(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Lookup an element in the cache, marking it as used more than once.
+func (c *Cache) Lookup(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok || !c.resident(el) {
+		return nil, false
+	}
+	c.move(el, t2)
+	return el.Value.(*entry).value, true
+}
+
+// Len returns the number of elements in the cache
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lists[t1].Len() + c.lists[t2].Len()
+}
+
+// Cap returns the capacity of the cache
+func (c *Cache) Cap() int {
+	return c.capacity
+}
+
+// Weight returns the total weight of the elements in the cache
+func (c *Cache) Weight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.weights[t1] + c.weights[t2]
+}
+
+// replace evicts elements into the ghost lists until an element of weight w fits,
+// taking them from t1 while it is over its target weight p, and otherwise from t2.
+func (c *Cache) replace(w int, ghostOfT2 bool) []*entry {
+	var evicted []*entry
+	for c.weights[t1]+c.weights[t2]+w > c.capacity {
+		from, to := t2, b2
+		t1w := c.weights[t1]
+		if c.lists[t1].Len() > 0 && (t1w > c.p || ghostOfT2 && t1w == c.p || c.lists[t2].Len() == 0) {
+			from, to = t1, b1
+		}
+
+		el := c.lists[from].Back()
+		e := el.Value.(*entry)
+		evicted = append(evicted, &entry{key: e.key, value: e.value})
+		e.value = nil
+		c.move(el, to)
+	}
+	return evicted
+}
+
+// trimGhosts drops the oldest ghosts until the ghost lists, together with the cache, weigh at most twice its capacity.
+func (c *Cache) trimGhosts() {
+	for c.total() > 2*c.capacity {
+		l := c.lists[b2]
+		if l.Len() == 0 {
+			l = c.lists[b1]
+		}
+		c.remove(l.Back())
+	}
+}
+
+// resident returns true if el holds a cached element rather than a ghost.
+func (c *Cache) resident(el *list.Element) bool {
+	l := el.Value.(*entry).list
+	return l == t1 || l == t2
+}
+
+// total returns the weight of all four lists.
+func (c *Cache) total() int {
+	return c.weights[t1] + c.weights[t2] + c.weights[b1] + c.weights[b2]
+}
+
+// push adds a new entry to the front of a list.
+func (c *Cache) push(e *entry, to int) {
+	e.list = to
+	c.items[e.key] = c.lists[to].PushFront(e)
+	c.weights[to] += e.weight
+}
+
+// move takes an element out of its list and puts it at the front of another, or its own.
+func (c *Cache) move(el *list.Element, to int) {
+	e := c.remove(el)
+	c.push(e, to)
+}
+
+// remove deletes an element from its list and the Cache, and returns its entry.
+func (c *Cache) remove(el *list.Element) *entry {
+	e := el.Value.(*entry)
+	c.lists[e.list].Remove(el)
+	c.weights[e.list] -= e.weight
+	delete(c.items, e.key)
+	return e
+}
+
+// delta returns how far to move the target weight of t1 after a ghost hit of weight w in a ghost list
+// of weight own, when the other ghost list weighs other. The smaller list moves it further.
+func delta(w, other, own int) int {
+	if own > 0 && other > own {
+		return w * other / own
+	}
+	return w
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
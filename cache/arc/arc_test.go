@@ -0,0 +1,63 @@
+package arc
+
+import (
+	"testing"
+
+	"github.com/frenata/gomua/cache"
+	"github.com/frenata/gomua/cache/cachetest"
+)
+
+func newCache(capacity int, weigh cache.Weigher, onEvict cache.EvictFunc) cache.Cache {
+	return NewWeighted(capacity, weigh, onEvict)
+}
+
+func TestCache(t *testing.T) { cachetest.Test(t, newCache) }
+
+func BenchmarkCache(b *testing.B) { cachetest.Benchmark(b, newCache) }
+
+func TestScanResistance(t *testing.T) {
+	c := New(10, nil)
+
+	// a working set used repeatedly moves to t2
+	for round := 0; round < 3; round++ {
+		for k := 0; k < 5; k++ {
+			if _, ok := c.Lookup(k); !ok {
+				c.Add(k, k)
+			}
+		}
+	}
+	// a long scan of keys used once must not flush it
+	for k := 100; k < 200; k++ {
+		c.Add(k, k)
+	}
+	for k := 0; k < 5; k++ {
+		if _, ok := c.Lookup(k); !ok {
+			t.Fatalf("working set element %d flushed by a scan", k)
+		}
+	}
+}
+
+func TestGhostHit(t *testing.T) {
+	c := New(4, nil)
+	for k := 0; k < 4; k++ {
+		c.Add(k, k)
+	}
+	c.Lookup(2)
+	c.Lookup(3)
+	// t1 is over its target, so adding 4 evicts 0 from t1 into b1
+	c.Add(4, 4)
+	if _, ok := c.Lookup(0); ok {
+		t.Fatal("0 was not evicted")
+	}
+	// adding 0 again shows t1 was evicted from too early, growing its target
+	c.Add(0, 0)
+	if c.p == 0 {
+		t.Fatal("ghost hit did not adapt the target size of t1")
+	}
+	if v, ok := c.Lookup(0); !ok || v != 0 {
+		t.Fatal("element re-added after a ghost hit is missing")
+	}
+	if c.Len() != 4 {
+		t.Fatalf("Len %d, expected 4", c.Len())
+	}
+}
@@ -1,13 +1,41 @@
-package cache
-
 // Package cache provides interfaces for cache functions
+package cache
 
-// Cache is the common interface implemented by all cache functions
+// Cache is the common interface implemented by all cache functions.
+// A Cache holds at most Cap elements, or elements of at most Cap total weight if it has a Weigher,
+// evicting others as needed to Add a new one.
+// Implementations are safe for concurrent use.
 type Cache interface {
-	// Add an element to the cache
-	Add()
+	// Add an element to the cache, replacing any element with the same key
+	Add(key, value interface{})
 	// Delete an element from the cache
-	Delete()
+	Delete(key interface{})
 	// Lookup an element in the cache
-	Lookup()
+	Lookup(key interface{}) (value interface{}, ok bool)
+	// Len returns the number of elements in the cache
+	Len() int
+	// Cap returns the capacity of the cache
+	Cap() int
+	// Weight returns the total weight of the elements in the cache, which without a Weigher is Len
+	Weight() int
+}
+
+// An EvictFunc is called with each element a Cache evicts to make room for another.
+// It is not called for elements removed with Delete or replaced with Add.
+type EvictFunc func(key, value interface{})
+
+// A Weigher returns the weight of an element, such as its size in bytes.
+// A Cache built with a Weigher holds elements up to a total weight of its capacity, instead of a
+// number of elements, and never holds an element heavier than its whole capacity.
+type Weigher func(key, value interface{}) int
+
+// weighOne is the Weigher of caches that count elements.
+func weighOne(key, value interface{}) int { return 1 }
+
+// WeighOrCount returns weigh, or if it is nil, a Weigher that gives every element a weight of one.
+func WeighOrCount(weigh Weigher) Weigher {
+	if weigh == nil {
+		return weighOne
+	}
+	return weigh
 }
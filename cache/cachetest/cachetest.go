@@ -0,0 +1,222 @@
+// Package cachetest checks that cache.Cache implementations behave alike, and benchmarks them against
+// the same workloads so that a policy can be chosen for a given use.
+package cachetest
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/frenata/gomua/cache"
+)
+
+// A NewFunc returns an empty Cache of the given capacity, weighed by weigh (nil to count elements),
+// that calls onEvict with each element it evicts.
+type NewFunc func(capacity int, weigh cache.Weigher, onEvict cache.EvictFunc) cache.Cache
+
+// weighInt weighs elements by their int value.
+func weighInt(key, value interface{}) int { return value.(int) }
+
+// Test runs the checks that every Cache implementation must pass.
+func Test(t *testing.T, newCache NewFunc) {
+	t.Run("Basic", func(t *testing.T) { testBasic(t, newCache) })
+	t.Run("Capacity", func(t *testing.T) { testCapacity(t, newCache) })
+	t.Run("Weighted", func(t *testing.T) { testWeighted(t, newCache) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, newCache) })
+}
+
+func testBasic(t *testing.T, newCache NewFunc) {
+	evictions := 0
+	c := newCache(4, nil, func(key, value interface{}) { evictions++ })
+
+	if _, ok := c.Lookup("a"); ok {
+		t.Fatal("empty cache found an element")
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("a", 3)
+	if v, ok := c.Lookup("a"); !ok || v != 3 {
+		t.Fatalf("replaced element reads back as %v, %v", v, ok)
+	}
+	if c.Len() != 2 || c.Weight() != 2 {
+		t.Fatalf("Len %d Weight %d, expected 2 and 2", c.Len(), c.Weight())
+	}
+
+	c.Delete("b")
+	c.Delete("missing")
+	if _, ok := c.Lookup("b"); ok || c.Len() != 1 {
+		t.Fatal("deleted element still in cache")
+	}
+	if evictions != 0 {
+		t.Fatalf("%d evictions without the cache filling up", evictions)
+	}
+	if c.Cap() != 4 {
+		t.Fatalf("Cap %d, expected 4", c.Cap())
+	}
+}
+
+func testCapacity(t *testing.T, newCache NewFunc) {
+	evicted := make(map[interface{}]bool)
+	c := newCache(10, nil, func(key, value interface{}) {
+		if key != value {
+			t.Errorf("evicted key %v with value %v", key, value)
+		}
+		evicted[key] = true
+	})
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		k := r.Intn(30)
+		if _, ok := c.Lookup(k); !ok {
+			c.Add(k, k)
+		}
+		if c.Len() > c.Cap() {
+			t.Fatalf("cache grew to %d elements, over its capacity of %d", c.Len(), c.Cap())
+		}
+	}
+	if c.Len() != c.Cap() {
+		t.Fatalf("full cache holds %d elements, expected %d", c.Len(), c.Cap())
+	}
+	if len(evicted) == 0 {
+		t.Fatal("no element evicted from a full cache")
+	}
+	for k := range evicted {
+		if v, ok := c.Lookup(k); ok && v != k {
+			t.Fatalf("key %v holds value %v", k, v)
+		}
+	}
+}
+
+func testWeighted(t *testing.T, newCache NewFunc) {
+	c := newCache(100, weighInt, nil)
+
+	for i := 0; i < 10; i++ {
+		c.Add(i, 10)
+	}
+	if c.Len() != 10 || c.Weight() != 100 {
+		t.Fatalf("Len %d Weight %d, expected 10 and 100", c.Len(), c.Weight())
+	}
+
+	c.Add("heavy", 101)
+	if _, ok := c.Lookup("heavy"); ok || c.Len() != 10 {
+		t.Fatal("element heavier than the capacity was added")
+	}
+
+	c.Add("big", 55)
+	if _, ok := c.Lookup("big"); !ok {
+		t.Fatal("big element was not added")
+	}
+	if c.Weight() > c.Cap() || c.Len() > 5 {
+		t.Fatalf("Len %d Weight %d after adding a big element, expected at most 5 and 100", c.Len(), c.Weight())
+	}
+
+	c.Add("big", 5)
+	if c.Weight() > c.Cap() {
+		t.Fatalf("Weight %d over capacity after replacing an element", c.Weight())
+	}
+}
+
+func testConcurrent(t *testing.T, newCache NewFunc) {
+	c := newCache(50, nil, nil)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := strconv.Itoa((g * i) % 120)
+				c.Add(k, i)
+				c.Lookup(k)
+				if i%7 == 0 {
+					c.Delete(k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if c.Len() > c.Cap() {
+		t.Fatalf("cache grew to %d elements, over its capacity of %d", c.Len(), c.Cap())
+	}
+}
+
+// Benchmark runs the shared benchmarks: the cost of each operation, and the hit ratios reached on
+// workloads shaped like reading mail, where a few messages are read far more often than the rest.
+func Benchmark(b *testing.B, newCache NewFunc) {
+	b.Run("Add", func(b *testing.B) {
+		c := newCache(1000, nil, nil)
+		for i := 0; i < b.N; i++ {
+			c.Add(i%5000, i)
+		}
+	})
+	b.Run("Lookup", func(b *testing.B) {
+		c := newCache(1000, nil, nil)
+		for i := 0; i < 1000; i++ {
+			c.Add(i, i)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Lookup(i % 2000)
+		}
+	})
+	b.Run("Zipf", func(b *testing.B) {
+		benchmarkWorkload(b, newCache(1000, nil, nil), 1)
+	})
+	b.Run("ZipfScan", func(b *testing.B) {
+		benchmarkWorkload(b, newCache(1000, nil, nil), 0.5)
+	})
+	b.Run("ZipfWeighted", func(b *testing.B) {
+		c := newCache(64<<20, func(key, value interface{}) int { return messageSize(key.(uint64)) }, nil)
+		benchmarkWorkload(b, c, 1)
+	})
+}
+
+// benchmarkWorkload reads messages from a mailbox of 100000, a Zipf distributed share of the reads going to the
+// popular messages and the rest scanning through the mailbox in order, as searching or paging through it does.
+// It reports the share of reads, and of bytes read, that hit the cache.
+func benchmarkWorkload(b *testing.B, c cache.Cache, zipfShare float64) {
+	const mailbox = 100000
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 10, mailbox-1)
+
+	var hits, hitBytes, bytes int
+	var scan uint64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var k uint64
+		if r.Float64() < zipfShare {
+			k = zipf.Uint64()
+		} else {
+			k = scan % mailbox
+			scan++
+		}
+
+		size := messageSize(k)
+		bytes += size
+		if _, ok := c.Lookup(k); ok {
+			hits++
+			hitBytes += size
+		} else {
+			c.Add(k, size)
+		}
+	}
+
+	b.ReportMetric(100*float64(hits)/float64(b.N), "hit%")
+	b.ReportMetric(100*float64(hitBytes)/float64(bytes), "bytehit%")
+}
+
+// messageSize returns the size of message k: mostly a few kilobytes, with one in fifty carrying
+// attachments of several megabytes.
+func messageSize(k uint64) int {
+	// splitmix64, to spread the sizes without the cost of seeding a generator for each message
+	h := k + 0x9E3779B97F4A7C15
+	h = (h ^ h>>30) * 0xBF58476D1CE4E5B9
+	h = (h ^ h>>27) * 0x94D049BB133111EB
+	h ^= h >> 31
+
+	if h%50 == 0 {
+		return 1<<20 + int(h>>8%(15<<20))
+	}
+	return 2<<10 + int(h>>8%(30<<10))
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Stamp identifies the contents of an indexed file: its name when last seen, and the modification
+// time and size it had when it was read.
+type Stamp struct {
+	Name    string
+	ModTime time.Time
+	Size    int64
+}
+
+// newStamp returns the Stamp of the file with the given path.
+func newStamp(path string, fi os.FileInfo) Stamp {
+	return Stamp{Name: filepath.Base(path), ModTime: fi.ModTime(), Size: fi.Size()}
+}
+
+// A fileSet tracks the Stamps of the entries of an index keyed by Key: which of them have been checked
+// against their files or added since the index was opened, and whether any has changed since it was saved.
+// Its methods are called with the lock of the index held.
+type fileSet struct {
+	stamps map[string]*Stamp
+	seen   map[string]bool
+	dirty  bool
+}
+
+func newFileSet() fileSet {
+	return fileSet{stamps: make(map[string]*Stamp), seen: make(map[string]bool)}
+}
+
+// current checks if the file with the given path has an entry whose Stamp still matches the file's
+// modification time and size, and returns its key if so. A renamed file is given its new name.
+func (s *fileSet) current(path string, fi os.FileInfo) (string, bool) {
+	key := Key(path)
+	st, ok := s.stamps[key]
+	if !ok || st.Size != fi.Size() || !st.ModTime.Equal(fi.ModTime()) {
+		return "", false
+	}
+
+	s.seen[key] = true
+	if name := filepath.Base(path); st.Name != name {
+		st.Name = name
+		s.dirty = true
+	}
+	return key, true
+}
+
+// add records the Stamp of a new or replaced entry.
+func (s *fileSet) add(key string, st *Stamp) {
+	s.stamps[key] = st
+	s.seen[key] = true
+	s.dirty = true
+}
+
+// remove forgets the Stamp of an entry.
+func (s *fileSet) remove(key string) {
+	if _, ok := s.stamps[key]; ok {
+		delete(s.stamps, key)
+		s.dirty = true
+	}
+}
+
+// unseen returns the keys of the entries that have not been checked or added since the index was opened,
+// which after a full scan are those of the files that no longer exist.
+func (s *fileSet) unseen() []string {
+	var keys []string
+	for key := range s.stamps {
+		if !s.seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// WriteFile replaces the file at path with what write writes to it, only once the new file is completely
+// written, so that a crash leaves either the old file or the new one.
+func WriteFile(path string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
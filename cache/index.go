@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// indexVersion is bumped whenever the on-disk Index format changes; older files are then discarded.
+const indexVersion = 2
+
+// An Index is an on-disk record of the parsed headers of the message files in a Maildir.
+// Entries are keyed by the unique part of each Maildir file name, without the info section holding its flags,
+// so that flag changes, and moves from new to cur, do not invalidate them.
+type Index struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+	files   fileSet
+}
+
+// An Entry is the indexed record of one message file.
+// Its Stamp identifies the file contents the Header was parsed from.
+type Entry struct {
+	Stamp
+	Header map[string][]string
+}
+
+// indexFile is the gob encoded form of an Index.
+type indexFile struct {
+	Version int
+	Entries map[string]*Entry
+}
+
+// Key returns the Index key of a Maildir file: its base name without the info section.
+func Key(filename string) string {
+	name := filepath.Base(filename)
+	if i := strings.LastIndex(name, ":2,"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// OpenIndex reads the Index stored at path. If there is no file at path yet, or it was written
+// in an older format, an empty Index is returned that Save will create. A corrupt file also
+// gives an empty Index, along with the error, so that Save replaces it.
+func OpenIndex(path string) (*Index, error) {
+	ix := &Index{path: path, entries: make(map[string]*Entry), files: newFileSet()}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file indexFile
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		ix.files.dirty = true
+		return ix, fmt.Errorf("index %s: %v", path, err)
+	}
+	if file.Version == indexVersion && file.Entries != nil {
+		ix.entries = file.Entries
+		for key, e := range file.Entries {
+			ix.files.stamps[key] = &e.Stamp
+		}
+	}
+	return ix, nil
+}
+
+// Lookup returns the Entry for the file with the given path, if it is indexed and the file's
+// modification time and size still match it. A renamed file is given its new name.
+func (ix *Index) Lookup(path string, fi os.FileInfo) (*Entry, bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	key, ok := ix.files.current(path, fi)
+	if !ok {
+		return nil, false
+	}
+	return ix.entries[key], true
+}
+
+// Add records the headers parsed from the file with the given path, replacing any previous Entry.
+func (ix *Index) Add(path string, fi os.FileInfo, header map[string][]string) {
+	key := Key(path)
+	e := &Entry{Stamp: newStamp(path, fi), Header: header}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.entries[key] = e
+	ix.files.add(key, &e.Stamp)
+}
+
+// Len returns the number of indexed files.
+func (ix *Index) Len() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return len(ix.entries)
+}
+
+// Prune removes the entries of every file that has not been looked up or added since the Index was opened,
+// which after a full scan are the files that no longer exist.
+func (ix *Index) Prune() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, key := range ix.files.unseen() {
+		delete(ix.entries, key)
+		ix.files.remove(key)
+	}
+}
+
+// Save writes the Index back to its file if it has changed.
+func (ix *Index) Save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if !ix.files.dirty {
+		return nil
+	}
+
+	err := WriteFile(ix.path, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(indexFile{Version: indexVersion, Entries: ix.entries})
+	})
+	if err != nil {
+		return err
+	}
+	ix.files.dirty = false
+	return nil
+}
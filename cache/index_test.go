@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	for _, name := range []string{
+		"/mail/new/1421823603.M63P1Q1.host",
+		"/mail/cur/1421823603.M63P1Q1.host:2,",
+		"/mail/cur/1421823603.M63P1Q1.host:2,RS",
+	} {
+		if k := Key(name); k != "1421823603.M63P1Q1.host" {
+			t.Errorf("Key(%s) = %s", name, k)
+		}
+	}
+}
+
+func TestIndexPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	msg := filepath.Join(dir, "1.M1P1Q1.host")
+	ioutil.WriteFile(msg, []byte("Subject: hi\r\n\r\nbody\r\n"), 0600)
+	gone := filepath.Join(dir, "2.M1P1Q1.host")
+	ioutil.WriteFile(gone, []byte("Subject: bye\r\n\r\nbody\r\n"), 0600)
+	fi, _ := os.Stat(msg)
+	goneFi, _ := os.Stat(gone)
+
+	path := filepath.Join(dir, "index")
+	ix, err := OpenIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix.Add(msg, fi, map[string][]string{"Subject": {"hi"}})
+	ix.Add(gone, goneFi, map[string][]string{"Subject": {"bye"}})
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a flag change renames the file without touching its contents
+	renamed := msg + ":2,S"
+	if err := os.Rename(msg, renamed); err != nil {
+		t.Fatal(err)
+	}
+	fi, _ = os.Stat(renamed)
+
+	ix, err = OpenIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := ix.Lookup(renamed, fi)
+	if !ok || e.Header["Subject"][0] != "hi" || e.Name != filepath.Base(renamed) {
+		t.Fatalf("renamed file not found in index: %v", e)
+	}
+
+	ix.Prune()
+	if ix.Len() != 1 {
+		t.Fatalf("prune left %d entries, expected 1", ix.Len())
+	}
+
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(renamed, later, later)
+	fi, _ = os.Stat(renamed)
+	if _, ok := ix.Lookup(renamed, fi); ok {
+		t.Fatal("modified file still found in index")
+	}
+}
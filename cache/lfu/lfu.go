@@ -1,10 +1,192 @@
+// Package lfu implements a least frequently used cache.Cache.
 package lfu
 
-func Add() {
+import (
+	"container/list"
+	"sync"
+
+	"github.com/frenata/gomua/cache"
+)
+
+var _ cache.Cache = (*Cache)(nil)
+
+// Cache is a cache.Cache that evicts the least frequently used element, and of those
+// the one least recently added to its frequency. Every operation is O(1).
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	weight   int
+	weigh    cache.Weigher
+	onEvict  cache.EvictFunc
+	items    map[interface{}]*entry
+	freqs    *list.List // of *freqNode, in increasing count order
+}
+
+// A freqNode holds every entry used the same number of times, least recently added first.
+type freqNode struct {
+	count   int
+	entries *list.List // of *entry
+}
+
+// An entry is one element of the Cache, with its place in the frequency lists.
+type entry struct {
+	key, value interface{}
+	weight     int
+	freq       *list.Element // in Cache.freqs
+	elem       *list.Element // in freqNode.entries
+}
+
+// New returns an empty Cache holding at most capacity elements.
+// If onEvict is not nil, it is called with every element evicted.
+func New(capacity int, onEvict cache.EvictFunc) *Cache {
+	return NewWeighted(capacity, nil, onEvict)
+}
+
+// NewWeighted returns an empty Cache holding elements up to a total weight of capacity, as given by weigh.
+// If onEvict is not nil, it is called with every element evicted.
+func NewWeighted(capacity int, weigh cache.Weigher, onEvict cache.EvictFunc) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		capacity: capacity,
+		weigh:    cache.WeighOrCount(weigh),
+		onEvict:  onEvict,
+		items:    make(map[interface{}]*entry),
+		freqs:    list.New(),
+	}
+}
+
+// Add an element to the cache, replacing any element with the same key.
+// Replacing an element counts as a use of it. An element heavier than the capacity is not added.
+func (c *Cache) Add(key, value interface{}) {
+	w := c.weigh(key, value)
+
+	c.mu.Lock()
+	e, ok := c.items[key]
+	switch {
+	case w > c.capacity:
+		if ok {
+			c.remove(e)
+		}
+		c.mu.Unlock()
+		return
+	case ok:
+		c.weight += w - e.weight
+		e.value, e.weight = value, w
+		c.increment(e)
+	default:
+		c.insert(&entry{key: key, value: value, weight: w})
+	}
+
+	var evicted []*entry
+	for c.weight > c.capacity {
+		evicted = append(evicted, c.evict(key))
+	}
+	c.mu.Unlock()
+
+	if c.onEvict != nil {
+		for _, e := range evicted {
+			c.onEvict(e.key, e.value)
+		}
+	}
+}
+
+// Delete an element from the cache
+func (c *Cache) Delete(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+}
+
+// Lookup an element in the cache, counting it as used.
+func (c *Cache) Lookup(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.increment(e)
+	return e.value, true
+}
+
+// Len returns the number of elements in the cache
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Cap returns the capacity of the cache
+func (c *Cache) Cap() int {
+	return c.capacity
+}
+
+// Weight returns the total weight of the elements in the cache
+func (c *Cache) Weight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.weight
+}
+
+// insert adds a new entry, placing it last among the entries used once.
+func (c *Cache) insert(e *entry) {
+	node := c.freqs.Front()
+	if node == nil || node.Value.(*freqNode).count != 1 {
+		node = c.freqs.PushFront(&freqNode{count: 1, entries: list.New()})
+	}
+
+	e.freq = node
+	e.elem = node.Value.(*freqNode).entries.PushBack(e)
+	c.items[e.key] = e
+	c.weight += e.weight
+}
+
+// increment moves an entry to the frequency node after its own, creating it if needed.
+func (c *Cache) increment(e *entry) {
+	cur := e.freq
+	count := cur.Value.(*freqNode).count + 1
+
+	next := cur.Next()
+	if next == nil || next.Value.(*freqNode).count != count {
+		next = c.freqs.InsertAfter(&freqNode{count: count, entries: list.New()}, cur)
+	}
+
+	c.unlink(e)
+	e.freq = next
+	e.elem = next.Value.(*freqNode).entries.PushBack(e)
+}
+
+// evict removes and returns the least frequently used entry, preferring any other entry to the one with key keep.
+func (c *Cache) evict(keep interface{}) *entry {
+	var victim *entry
+	for node := c.freqs.Front(); node != nil && victim == nil; node = node.Next() {
+		for el := node.Value.(*freqNode).entries.Front(); el != nil; el = el.Next() {
+			if e := el.Value.(*entry); e.key != keep || len(c.items) == 1 {
+				victim = e
+				break
+			}
+		}
+	}
+	c.remove(victim)
+	return victim
 }
 
-func Delete() {
+// remove deletes an entry from the Cache entirely.
+func (c *Cache) remove(e *entry) {
+	c.unlink(e)
+	delete(c.items, e.key)
+	c.weight -= e.weight
 }
 
-func Lookup() {
+// unlink takes an entry out of its frequency node, dropping the node if it is left empty.
+func (c *Cache) unlink(e *entry) {
+	node := e.freq.Value.(*freqNode)
+	node.entries.Remove(e.elem)
+	if node.entries.Len() == 0 {
+		c.freqs.Remove(e.freq)
+	}
 }
@@ -0,0 +1,86 @@
+package lfu
+
+import (
+	"testing"
+
+	"github.com/frenata/gomua/cache"
+	"github.com/frenata/gomua/cache/cachetest"
+)
+
+func newCache(capacity int, weigh cache.Weigher, onEvict cache.EvictFunc) cache.Cache {
+	return NewWeighted(capacity, weigh, onEvict)
+}
+
+func TestCache(t *testing.T) { cachetest.Test(t, newCache) }
+
+func BenchmarkCache(b *testing.B) { cachetest.Benchmark(b, newCache) }
+
+func TestEviction(t *testing.T) {
+	var evicted []interface{}
+	c := New(3, func(key, value interface{}) { evicted = append(evicted, key) })
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	c.Lookup("a")
+	c.Lookup("a")
+	c.Lookup("c")
+
+	// b is least frequently used
+	c.Add("d", 4)
+	if _, ok := c.Lookup("b"); ok {
+		t.Fatal("least frequently used element was not evicted")
+	}
+	// d, just added, is now the only element used once
+	c.Add("e", 5)
+	if _, ok := c.Lookup("d"); ok {
+		t.Fatal("newly added element was not evicted")
+	}
+
+	if len(evicted) != 2 || evicted[0] != "b" || evicted[1] != "d" {
+		t.Fatalf("evicted %v, expected [b d]", evicted)
+	}
+	if v, ok := c.Lookup("a"); !ok || v != 1 {
+		t.Fatalf("frequently used element lost: %v %v", v, ok)
+	}
+	if c.Len() != 3 || c.Cap() != 3 {
+		t.Fatalf("Len %d Cap %d, expected 3 and 3", c.Len(), c.Cap())
+	}
+}
+
+func TestEvictionTie(t *testing.T) {
+	var evicted []interface{}
+	c := New(2, func(key, value interface{}) { evicted = append(evicted, key) })
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Lookup("b")
+	c.Lookup("a")
+
+	// a and b are used equally often, and b reached that count first
+	c.Add("c", 3)
+	// c, used once, is now the least frequently used
+	c.Add("d", 4)
+	if len(evicted) != 2 || evicted[0] != "b" || evicted[1] != "c" {
+		t.Fatalf("evicted %v, expected [b c]", evicted)
+	}
+}
+
+func TestReplaceAndDelete(t *testing.T) {
+	evictions := 0
+	c := New(2, func(key, value interface{}) { evictions++ })
+
+	c.Add("a", 1)
+	c.Add("a", 2)
+	if v, _ := c.Lookup("a"); v != 2 {
+		t.Fatalf("replaced element reads back as %v", v)
+	}
+	c.Delete("a")
+	c.Delete("missing")
+	if _, ok := c.Lookup("a"); ok || c.Len() != 0 {
+		t.Fatal("deleted element still in cache")
+	}
+	if evictions != 0 {
+		t.Fatalf("replace and delete caused %d evictions", evictions)
+	}
+}
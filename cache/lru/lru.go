@@ -0,0 +1,134 @@
+// Package lru implements a least recently used cache.Cache.
+package lru
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/frenata/gomua/cache"
+)
+
+var _ cache.Cache = (*Cache)(nil)
+
+// Cache is a cache.Cache that evicts the least recently used element. Every operation is O(1).
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	weight   int
+	weigh    cache.Weigher
+	onEvict  cache.EvictFunc
+	items    map[interface{}]*list.Element
+	order    *list.List // of *entry, most recently used first
+}
+
+// An entry is one element of the Cache.
+type entry struct {
+	key, value interface{}
+	weight     int
+}
+
+// New returns an empty Cache holding at most capacity elements.
+// If onEvict is not nil, it is called with every element evicted.
+func New(capacity int, onEvict cache.EvictFunc) *Cache {
+	return NewWeighted(capacity, nil, onEvict)
+}
+
+// NewWeighted returns an empty Cache holding elements up to a total weight of capacity, as given by weigh.
+// If onEvict is not nil, it is called with every element evicted.
+func NewWeighted(capacity int, weigh cache.Weigher, onEvict cache.EvictFunc) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		capacity: capacity,
+		weigh:    cache.WeighOrCount(weigh),
+		onEvict:  onEvict,
+		items:    make(map[interface{}]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Add an element to the cache, replacing any element with the same key, and marking it most recently used.
+// An element heavier than the capacity is not added.
+func (c *Cache) Add(key, value interface{}) {
+	w := c.weigh(key, value)
+
+	c.mu.Lock()
+	el, ok := c.items[key]
+	switch {
+	case w > c.capacity:
+		if ok {
+			c.remove(el)
+		}
+		c.mu.Unlock()
+		return
+	case ok:
+		e := el.Value.(*entry)
+		c.weight += w - e.weight
+		e.value, e.weight = value, w
+		c.order.MoveToFront(el)
+	default:
+		c.items[key] = c.order.PushFront(&entry{key: key, value: value, weight: w})
+		c.weight += w
+	}
+
+	var evicted []*entry
+	for c.weight > c.capacity {
+		evicted = append(evicted, c.remove(c.order.Back()))
+	}
+	c.mu.Unlock()
+
+	if c.onEvict != nil {
+		for _, e := range evicted {
+			c.onEvict(e.key, e.value)
+		}
+	}
+}
+
+// Delete an element from the cache
+func (c *Cache) Delete(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Lookup an element in the cache, marking it most recently used.
+func (c *Cache) Lookup(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Len returns the number of elements in the cache
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Cap returns the capacity of the cache
+func (c *Cache) Cap() int {
+	return c.capacity
+}
+
+// Weight returns the total weight of the elements in the cache
+func (c *Cache) Weight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.weight
+}
+
+// remove deletes an element from the Cache and returns its entry.
+func (c *Cache) remove(el *list.Element) *entry {
+	e := c.order.Remove(el).(*entry)
+	delete(c.items, e.key)
+	c.weight -= e.weight
+	return e
+}
@@ -0,0 +1,30 @@
+package lru
+
+import (
+	"testing"
+
+	"github.com/frenata/gomua/cache"
+	"github.com/frenata/gomua/cache/cachetest"
+)
+
+func newCache(capacity int, weigh cache.Weigher, onEvict cache.EvictFunc) cache.Cache {
+	return NewWeighted(capacity, weigh, onEvict)
+}
+
+func TestCache(t *testing.T) { cachetest.Test(t, newCache) }
+
+func BenchmarkCache(b *testing.B) { cachetest.Benchmark(b, newCache) }
+
+func TestEviction(t *testing.T) {
+	var evicted []interface{}
+	c := New(2, func(key, value interface{}) { evicted = append(evicted, key) })
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Lookup("a")
+	c.Add("c", 3)
+	c.Add("d", 4)
+	if len(evicted) != 2 || evicted[0] != "b" || evicted[1] != "a" {
+		t.Fatalf("evicted %v, expected [b a]", evicted)
+	}
+}
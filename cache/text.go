@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/frenata/gomua/words"
+)
+
+// textIndexVersion is bumped whenever the on-disk TextIndex format, or the way text is split into words, changes.
+const textIndexVersion = 3
+
+// A TextIndex is an on-disk inverted index of the words in the text of the message files in a Maildir.
+// Like an Index, it is keyed by the unique part of each file name, so that only new and changed files
+// have to be read again to bring it up to date.
+type TextIndex struct {
+	path string
+	stem bool
+
+	mu    sync.Mutex
+	docs  map[string]*TextEntry
+	words map[string]map[string]bool // word, to the keys of the files holding it
+	files fileSet
+}
+
+// A TextEntry is the indexed record of one message file: the distinct words of its text.
+// Its Stamp identifies the file contents the Words were read from.
+type TextEntry struct {
+	Stamp
+	Words []string
+}
+
+// textIndexFile is the gob encoded form of a TextIndex.
+type textIndexFile struct {
+	Version int
+	Stem    bool
+	Docs    map[string]*TextEntry
+}
+
+// OpenTextIndex reads the TextIndex stored at path. If stem is set, words are indexed by their stems, which
+// keeps the index smaller but narrows searches for words of up to words.StemCut letters less. As with OpenIndex, a missing or outdated file,
+// or one written with a different stem setting, gives an empty TextIndex, and a corrupt one an empty TextIndex
+// along with the error.
+func OpenTextIndex(path string, stem bool) (*TextIndex, error) {
+	ix := &TextIndex{path: path, stem: stem}
+	ix.reset()
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file textIndexFile
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		ix.files.dirty = true
+		return ix, fmt.Errorf("text index %s: %v", path, err)
+	}
+	if file.Version != textIndexVersion || file.Stem != stem || file.Docs == nil {
+		ix.files.dirty = true
+		return ix, nil
+	}
+	for key, e := range file.Docs {
+		ix.insert(key, e)
+	}
+	return ix, nil
+}
+
+// reset empties the TextIndex.
+func (ix *TextIndex) reset() {
+	ix.docs = make(map[string]*TextEntry)
+	ix.words = make(map[string]map[string]bool)
+	ix.files = newFileSet()
+}
+
+// Reset removes every entry, so that the TextIndex can be rebuilt from scratch.
+func (ix *TextIndex) Reset() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.reset()
+	ix.files.dirty = true
+}
+
+// Current checks if the TextIndex holds the words of the file with the given path, read since its
+// modification time or size last changed. A renamed file is given its new name.
+func (ix *TextIndex) Current(path string, fi os.FileInfo) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	_, ok := ix.files.current(path, fi)
+	return ok
+}
+
+// Add records the words of the text of the file with the given path, replacing any previous entry.
+func (ix *TextIndex) Add(path string, fi os.FileInfo, text string) {
+	key := Key(path)
+	e := &TextEntry{Stamp: newStamp(path, fi), Words: ix.distinctWords(text)}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+	ix.insert(key, e)
+	ix.files.add(key, &e.Stamp)
+}
+
+// Indexed checks if the file with the given path has an entry, whether or not it is current.
+func (ix *TextIndex) Indexed(path string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	_, ok := ix.docs[Key(path)]
+	return ok
+}
+
+// Search returns the keys, as given by Key, of the files whose text may contain text: those holding, for each
+// word of text, a word it is part of. Every file whose text contains text is among them, along with others,
+// so each has to be checked. It returns false if text has no words that are indexed.
+func (ix *TextIndex) Search(text string) (map[string]bool, bool) {
+	ws := words.Split(text)
+	if len(ws) == 0 {
+		return nil, false
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	var keys map[string]bool
+	for _, w := range ws {
+		found := make(map[string]bool)
+		for indexed, docs := range ix.words {
+			if !ix.partOf(w, indexed) {
+				continue
+			}
+			for key := range docs {
+				if keys == nil || keys[key] {
+					found[key] = true
+				}
+			}
+		}
+		keys = found
+	}
+	return keys, true
+}
+
+// partOf checks if w may be part of a word that was indexed as indexed. A stem stands for any word it begins,
+// less its last letter, that is at most words.StemCut letters longer.
+func (ix *TextIndex) partOf(w, indexed string) bool {
+	if strings.Contains(indexed, w) {
+		return true
+	}
+	if !ix.stem || indexed == "" {
+		return false
+	}
+	start := indexed[:len(indexed)-1]
+	for n := len(w) - words.StemCut; n < len(w); n++ {
+		if n <= 0 || strings.HasSuffix(start, w[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of indexed files.
+func (ix *TextIndex) Len() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return len(ix.docs)
+}
+
+// Prune removes the entries of every file that has not been checked with Current or added since the
+// TextIndex was opened, which after a full scan are the files that no longer exist.
+func (ix *TextIndex) Prune() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, key := range ix.files.unseen() {
+		ix.remove(key)
+	}
+}
+
+// Save writes the TextIndex back to its file if it has changed.
+func (ix *TextIndex) Save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if !ix.files.dirty {
+		return nil
+	}
+
+	err := WriteFile(ix.path, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(textIndexFile{Version: textIndexVersion, Stem: ix.stem, Docs: ix.docs})
+	})
+	if err != nil {
+		return err
+	}
+	ix.files.dirty = false
+	return nil
+}
+
+// insert adds an entry and its words.
+func (ix *TextIndex) insert(key string, e *TextEntry) {
+	ix.docs[key] = e
+	ix.files.stamps[key] = &e.Stamp
+	for _, w := range e.Words {
+		docs, ok := ix.words[w]
+		if !ok {
+			docs = make(map[string]bool)
+			ix.words[w] = docs
+		}
+		docs[key] = true
+	}
+}
+
+// remove deletes an entry and its words, if there is one.
+func (ix *TextIndex) remove(key string) {
+	e, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	for _, w := range e.Words {
+		delete(ix.words[w], key)
+		if len(ix.words[w]) == 0 {
+			delete(ix.words, w)
+		}
+	}
+	delete(ix.docs, key)
+	ix.files.remove(key)
+}
+
+// distinctWords returns each word of text once, in the order first seen, stemmed if the TextIndex stems.
+func (ix *TextIndex) distinctWords(text string) []string {
+	var distinct []string
+	seen := make(map[string]bool)
+	for _, w := range words.Split(text) {
+		if ix.stem {
+			w = words.Stem(w)
+		}
+		if !seen[w] {
+			seen[w] = true
+			distinct = append(distinct, w)
+		}
+	}
+	return distinct
+}
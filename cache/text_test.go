@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTextIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, body string) (string, os.FileInfo) {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(body), 0600)
+		fi, _ := os.Stat(path)
+		return path, fi
+	}
+	a, aFi := write("1.M1P1Q1.host", "a")
+	b, bFi := write("2.M1P1Q1.host", "b")
+
+	path := filepath.Join(dir, "text")
+	ix, err := OpenTextIndex(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix.Add(a, aFi, "The meetings are on Monday")
+	ix.Add(b, bFi, "No meeting on Tuesday")
+
+	keys, ok := ix.Search("Meeting mondays")
+	if !ok || len(keys) != 1 || !keys[Key(a)] {
+		t.Fatalf("search found %v, %v", keys, ok)
+	}
+	if keys, _ := ix.Search("eting"); len(keys) != 2 {
+		t.Fatalf("search for part of a word found %d files, expected 2", len(keys))
+	}
+	if keys, _ := ix.Search("meetings"); len(keys) != 2 {
+		t.Fatalf("search for a word with the same stem found %d files, expected 2", len(keys))
+	}
+	if _, ok := ix.Search("a ! ?"); ok {
+		t.Fatal("search without words reported results")
+	}
+
+	// b is replaced, and renamed with flags
+	ix.Add(b, bFi, "Tuesday is off")
+	if keys, _ := ix.Search("meeting"); len(keys) != 1 {
+		t.Fatalf("replaced file still found by its old words")
+	}
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+	renamed := b + ":2,S"
+	os.Rename(b, renamed)
+
+	ix, err = OpenTextIndex(path, true)
+	if err != nil || ix.Len() != 2 {
+		t.Fatalf("reopened index holds %d files, %v", ix.Len(), err)
+	}
+	renamedFi, _ := os.Stat(renamed)
+	if !ix.Current(renamed, renamedFi) || !ix.Indexed(renamed) {
+		t.Fatal("renamed file is not current")
+	}
+	ix.Prune()
+	if ix.Len() != 1 || ix.Indexed(a) {
+		t.Fatal("prune kept a file that was not seen")
+	}
+	if keys, _ := ix.Search("mondays"); len(keys) != 0 {
+		t.Fatal("pruned file still found")
+	}
+
+	// a different stem setting starts over
+	ix.Save()
+	if ix, _ = OpenTextIndex(path, false); ix.Len() != 0 {
+		t.Fatal("index opened with another stem setting kept its entries")
+	}
+}
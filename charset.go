@@ -0,0 +1,126 @@
+package gomua
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// A CharsetDecoder converts text from some character set into UTF-8.
+type CharsetDecoder func([]byte) ([]byte, error)
+
+// charsets maps normalized charset names to their decoders.
+var charsets = struct {
+	sync.RWMutex
+	m map[string]CharsetDecoder
+}{m: make(map[string]CharsetDecoder)}
+
+func init() {
+	RegisterCharset(decodeUTF8, "utf-8", "utf8", "us-ascii", "ascii", "ansi_x3.4-1968")
+	RegisterCharset(decodeLatin1, "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1")
+	RegisterCharset(decodeLatin9, "iso-8859-15", "iso8859-15", "iso_8859-15", "latin-9", "latin9")
+	RegisterCharset(decodeWindows1252, "windows-1252", "cp1252", "x-cp1252")
+}
+
+// RegisterCharset makes dec available for each of the given charset names.
+// Names are matched case insensitively, and a later registration replaces an earlier one.
+func RegisterCharset(dec CharsetDecoder, names ...string) {
+	charsets.Lock()
+	defer charsets.Unlock()
+	for _, n := range names {
+		charsets.m[normalizeCharset(n)] = dec
+	}
+}
+
+// DecodeCharset converts b from the named charset into UTF-8.
+// An empty charset is taken to be US-ASCII, as RFC 2045 specifies.
+func DecodeCharset(charset string, b []byte) ([]byte, error) {
+	if charset == "" {
+		charset = "us-ascii"
+	}
+
+	charsets.RLock()
+	dec, ok := charsets.m[normalizeCharset(charset)]
+	charsets.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return dec(b)
+}
+
+// normalizeCharset returns the registry key of a charset name.
+func normalizeCharset(name string) string {
+	return strings.ToLower(strings.Trim(name, " \t\"'"))
+}
+
+// toUTF8 decodes b with DecodeCharset, falling back to the raw bytes for unknown charsets.
+func toUTF8(charset string, b []byte) string {
+	d, err := DecodeCharset(charset, b)
+	if err != nil {
+		return string(b)
+	}
+	return string(d)
+}
+
+// decodeUTF8 passes valid UTF-8 (which includes ASCII) through untouched.
+// Mislabelled 8-bit text is common enough that each byte that is not part of a valid UTF-8 sequence
+// is read as Windows-1252 instead, leaving the valid characters around it as they are.
+func decodeUTF8(b []byte) ([]byte, error) {
+	if utf8.Valid(b) {
+		return b, nil
+	}
+	out := make([]byte, 0, len(b)+len(b)/2)
+	for len(b) > 0 {
+		r, n := utf8.DecodeRune(b)
+		if r == utf8.RuneError && n == 1 {
+			out = append(out, decodeSingleByte(b[:1], windows1252)...)
+		} else {
+			out = append(out, b[:n]...)
+		}
+		b = b[n:]
+	}
+	return out, nil
+}
+
+// decodeLatin1 maps each ISO-8859-1 byte directly to the identical code point.
+func decodeLatin1(b []byte) ([]byte, error) {
+	return decodeSingleByte(b, nil), nil
+}
+
+// decodeLatin9 decodes ISO-8859-15, which differs from ISO-8859-1 in eight positions.
+func decodeLatin9(b []byte) ([]byte, error) {
+	return decodeSingleByte(b, latin9), nil
+}
+
+// decodeWindows1252 decodes Windows-1252, which replaces the ISO-8859-1 C1 controls with printable characters.
+func decodeWindows1252(b []byte) ([]byte, error) {
+	return decodeSingleByte(b, windows1252), nil
+}
+
+// decodeSingleByte converts a single byte charset that is ISO-8859-1 apart from the bytes in overrides.
+func decodeSingleByte(b []byte, overrides map[byte]rune) []byte {
+	out := make([]byte, 0, len(b))
+	buf := make([]byte, utf8.UTFMax)
+	for _, c := range b {
+		r := rune(c)
+		if o, ok := overrides[c]; ok {
+			r = o
+		}
+		n := utf8.EncodeRune(buf, r)
+		out = append(out, buf[:n]...)
+	}
+	return out
+}
+
+var latin9 = map[byte]rune{
+	0xA4: '€', 0xA6: 'Š', 0xA8: 'š', 0xB4: 'Ž',
+	0xB8: 'ž', 0xBC: 'Œ', 0xBD: 'œ', 0xBE: 'Ÿ',
+}
+
+var windows1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
@@ -0,0 +1,51 @@
+package gomua_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/frenata/gomua"
+)
+
+func Test_DecodeCharset(t *testing.T) {
+	tests := []struct {
+		charset string
+		in      string
+		want    string
+	}{
+		{"ISO-8859-1", "caf\xe9", "café"},
+		{"iso-8859-15", "\xa4 5", "€ 5"},
+		{"windows-1252", "\x93quoted\x94", "“quoted”"},
+		{"us-ascii", "plain", "plain"},
+		{"utf-8", "na\xefve", "naïve"},
+		{"utf-8", "caf\xc3\xa9 \x93quoted\x94 \xe2\x82\xac", "café “quoted” €"},
+	}
+
+	for _, tt := range tests {
+		got, err := gomua.DecodeCharset(tt.charset, []byte(tt.in))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.charset, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.charset, tt.want, got)
+		}
+	}
+
+	if _, err := gomua.DecodeCharset("x-unknown", []byte("a")); err == nil {
+		t.Errorf("unknown charset did not return an error")
+	}
+}
+
+func Test_RegisterCharset(t *testing.T) {
+	upper := func(b []byte) ([]byte, error) { return []byte(strings.ToUpper(string(b))), nil }
+	gomua.RegisterCharset(upper, "x-test-upper")
+
+	msg := "Content-Type: text/plain; charset=X-Test-Upper\r\n\r\nshout\r\n"
+	m, err := gomua.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := m.SanitizeContent(); text != "SHOUT\r\n" {
+		t.Fatalf("registered charset not used, got %q", text)
+	}
+}
@@ -78,7 +78,10 @@ func main() {
 
 	for i := 0; i < flag.NArg(); i++ {
 		path := flag.Arg(i)
-		gomua.Scan(path)
+		for _, err := range gomua.ScanPath(path).Errors {
+			fmt.Println(err)
+			exitCode = 2
+		}
 	}
 	os.Exit(exitCode)
 }
@@ -2,7 +2,7 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -15,26 +15,73 @@ import (
 	"strings"
 
 	"github.com/frenata/gomua"
+	"github.com/frenata/gomua/cache"
+	"github.com/frenata/gomua/cache/lfu"
 	"github.com/frenata/gomua/send"
 )
 
 // client handles common data as a user navigates the MUA.
-// messages is the list of all mail (in the current folder?)
+// messages is the list of all mail in the current folder.
+// list is the mail shown to the user and numbered: the messages, or their threads if threaded is set,
+// in the order given by sortKey and sortReverse. If query is set, only the mail matching it is listed.
 // current is the currently selected Mail
 // displayN is the # of Mail to display on the screen at one time.
 // user is the user's email address, for sending.
+// dir is the root Maildir, and folder the name of the Maildir++ folder being read.
+// scanWorkers is the # of files read at once when scanning a folder, 0 for one per CPU.
+// bodies caches the most frequently read messages, rendered for display.
+// text indexes the words of the messages in the current folder for searches, stemmed if stem is set.
+// It is nil until a search of the folder needs it.
+// searches are the saved searches of the config file, and saved the one open instead of a folder, if any.
+// tags holds the user's tags for messages in every folder.
 type client struct {
-	messages   []gomua.Mail
-	current    gomua.Mail
-	displayN   int
-	user       string
-	dir        string
-	configFile string
+	messages    []gomua.Mail
+	list        []gomua.Mail
+	threaded    bool
+	sortKey     gomua.SortKey
+	sortReverse bool
+	query       *gomua.Query
+	current     gomua.Mail
+	displayN    int
+	user        string
+	dir         string
+	folder      string
+	scanWorkers int
+	bodies      cache.Cache
+	text        *cache.TextIndex
+	stem        bool
+	searches    []*savedSearch
+	saved       *savedSearch
+	tags        *gomua.TagDB
+	configFile  string
 }
 
+// the folder rm moves messages to, if it exists
+const trashFolder = "Trash"
+
+// the total size of the rendered messages kept in memory
+const bodyCacheBytes = 16 << 20
+
+// weighs rendered messages in the body cache by their size
+func textSize(key, value interface{}) int { return len(value.(string)) }
+
+// the file in each folder that holds the index of its message headers
+const indexFile = "gomua.index"
+
+// the file in each folder that holds the index of the words of its messages
+const textIndexFile = "gomua.text"
+
+// the file in the Maildir that holds the tags of the messages in all its folders
+const tagsFile = "gomua.tags"
+
 // reads from the config file, creates a new client
 func newClient(filename string) (*client, error) {
-	c := new(client)
+	c := &client{
+		folder:      gomua.Inbox,
+		bodies:      lfu.NewWeighted(bodyCacheBytes, textSize, nil),
+		sortKey:     gomua.ByDate,
+		sortReverse: true,
+	}
 
 	// TODO: much of the following is duplicated from send.go, refactor
 	b, err := ioutil.ReadFile(filename)
@@ -60,33 +107,117 @@ func newClient(filename string) (*client, error) {
 			c.displayN, _ = strconv.Atoi(strings.TrimPrefix(l, "DisplayN="))
 		case strings.HasPrefix(l, "User="):
 			c.user = strings.TrimPrefix(l, "User=")
+		case strings.HasPrefix(l, "ScanWorkers="):
+			c.scanWorkers, _ = strconv.Atoi(strings.TrimPrefix(l, "ScanWorkers="))
+		case strings.HasPrefix(l, "Stemming="):
+			c.stem, _ = strconv.ParseBool(strings.TrimSpace(strings.TrimPrefix(l, "Stemming=")))
 		}
 	}
 
 	if c.dir == "" || c.user == "" || c.displayN == 0 {
 		return nil, errors.New("Client: incorrect " + filename + " file.")
 	}
+	if c.searches, err = parseSearches(string(b), c.dir); err != nil {
+		return nil, fmt.Errorf("Client: %v in %s file", err, filename)
+	}
+	if c.tags, err = gomua.OpenTagDB(filepath.Join(c.dir, tagsFile)); err != nil {
+		return nil, err
+	}
 
 	return c, nil
 }
 
-// takes a Maildir directory, scans for messages, and returns a slice of Message structs.
-func (c *client) scanMailDir(dir string) {
-	var msgs []gomua.Mail
+// a named query from the config file, listed and opened like a folder.
+// folders are the names of the folders searched, or nil for every folder.
+type savedSearch struct {
+	name    string
+	query   *gomua.Query
+	folders []string
+}
+
+// reads the saved searches from the [search name] sections of the config file:
+//
+//	[search needs reply]
+//	Query=is:unread -is:replied
+//	Folders=INBOX,Work
+//
+// The folders must exist in the Maildir dir.
+func parseSearches(config, dir string) ([]*savedSearch, error) {
+	var searches []*savedSearch
+	var s *savedSearch
+	for _, l := range strings.Split(config, "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]"):
+			s = nil
+			if name := strings.TrimPrefix(l[1:len(l)-1], "search "); name != l[1:len(l)-1] {
+				s = &savedSearch{name: strings.TrimSpace(name)}
+				searches = append(searches, s)
+			}
+		case s == nil:
+		case strings.HasPrefix(l, "Query="):
+			q, err := gomua.ParseQuery(strings.TrimPrefix(l, "Query="))
+			if err != nil {
+				return nil, fmt.Errorf("search %s: %v", s.name, err)
+			}
+			s.query = q
+		case strings.HasPrefix(l, "Folders="):
+			for _, f := range strings.Split(strings.TrimPrefix(l, "Folders="), ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					s.folders = append(s.folders, f)
+				}
+			}
+		}
+	}
+
+	for _, s := range searches {
+		if s.name == "" || s.query == nil {
+			return nil, fmt.Errorf("search %s: missing name or Query", s.name)
+		}
+		for _, f := range s.folders {
+			if _, err := os.Stat(filepath.Join(string(gomua.Maildir(dir).Folder(f)), "cur")); err != nil {
+				return nil, fmt.Errorf("search %s: no folder %s", s.name, f)
+			}
+		}
+	}
+	return searches, nil
+}
+
+// scans the new and cur directories of a folder for messages, reading the headers of unchanged files
+// from the folder's index instead of the files themselves
+func (c *client) scanFolder(dir string) (newmail, curmail []gomua.Mail) {
+	ix, err := cache.OpenIndex(filepath.Join(dir, indexFile))
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	sc := &gomua.Scanner{Workers: c.scanWorkers, Index: ix}
+	newscan, _ := sc.Scan(context.Background(), filepath.Join(dir, "new"))
+	curscan, _ := sc.Scan(context.Background(), filepath.Join(dir, "cur"))
+	for _, err := range append(newscan.Errors, curscan.Errors...) {
+		fmt.Println(err)
+	}
+
+	if ix != nil {
+		ix.Prune()
+		if err := ix.Save(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	return newscan.Messages, curscan.Messages
+}
 
-	// scan new and cur folder
-	newmail := gomua.Scan(filepath.Join(dir, "new"))
-	curmail := gomua.Scan(filepath.Join(dir, "cur"))
+// scans a folder for messages like scanFolder, moving new mail to cur so that it can be flagged,
+// and returns them all
+func (c *client) readFolder(dir string) []gomua.Mail {
+	var msgs []gomua.Mail
+	newmail, curmail := c.scanFolder(dir)
 
 	for _, m := range newmail {
 		if m, ok := m.(*gomua.Message); ok {
-			folder, _ := filepath.Split(m.Filename())
-			root := strings.TrimRight(folder, "/new/")
-			newpath := filepath.Join(root, "cur")
-
-			err := m.Move(newpath)
+			err := m.Move(filepath.Join(dir, "cur"))
 			if err != nil {
-				log.Fatal(err)
+				fmt.Println(err)
 			}
 		}
 		msgs = append(msgs, m)
@@ -95,51 +226,376 @@ func (c *client) scanMailDir(dir string) {
 	for _, cm := range curmail {
 		msgs = append(msgs, cm)
 	}
+	return msgs
+}
+
+// takes a Maildir directory, scans for messages, and makes them the messages of the client,
+// moving new mail to cur.
+func (c *client) scanMailDir(dir string) {
+	msgs := c.readFolder(dir)
+	c.messages = msgs
+	c.saved = nil
+	c.text = nil
+	c.refreshList()
+}
+
+// brings the text index of a folder up to date with its messages, or rebuilds it from scratch if rebuild is set,
+// and returns it along with how many messages were read
+func (c *client) indexText(dir string, msgs []gomua.Mail, rebuild bool) (*cache.TextIndex, int) {
+	ix, err := cache.OpenTextIndex(filepath.Join(dir, textIndexFile), c.stem)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if ix == nil {
+		return nil, 0
+	}
+
+	if rebuild {
+		ix.Reset()
+	}
+	n, err := gomua.IndexText(ix, msgs)
+	if err != nil {
+		fmt.Println(err)
+	}
+	ix.Prune()
+	if err := ix.Save(); err != nil {
+		fmt.Println(err)
+	}
+	return ix, n
+}
+
+// returns the directories of the folders a saved search looks in
+func (c *client) searchDirs(s *savedSearch) ([]string, error) {
+	names := s.folders
+	if names == nil {
+		folders, err := gomua.Maildir(c.dir).Folders()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range folders {
+			names = append(names, f.Name)
+		}
+	}
+
+	var dirs []string
+	for _, n := range names {
+		dirs = append(dirs, string(gomua.Maildir(c.dir).Folder(n)))
+	}
+	return dirs, nil
+}
+
+// runs a saved search over its folders, with each folder's text index, and returns the matching messages.
+// If reindex is set, the text indexes are rebuilt first.
+func (c *client) runSearch(s *savedSearch, reindex bool) ([]gomua.Mail, error) {
+	dirs, err := c.searchDirs(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []gomua.Mail
+	for _, dir := range dirs {
+		msgs := c.readFolder(dir)
+		s.query.Index = nil
+		if reindex || s.query.SearchesText() {
+			s.query.Index, _ = c.indexText(dir, msgs, reindex)
+		}
+		s.query.Tags = c.tags
+		matches = append(matches, s.query.Filter(msgs)...)
+	}
+	s.query.Index = nil
+	return matches, nil
+}
 
+// opens a saved search as if it were a folder, listing the messages that match it
+func (c *client) openSearch(s *savedSearch) error {
+	msgs, err := c.runSearch(s, false)
+	if err != nil {
+		return err
+	}
+	c.folder = s.name
+	c.query = nil
 	c.messages = msgs
+	c.saved = s
+	c.text = nil
+	c.refreshList()
+	return nil
+}
+
+// returns the saved search with the given name, or nil if there is none
+func (c *client) savedSearch(name string) *savedSearch {
+	for _, s := range c.searches {
+		if strings.EqualFold(s.name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// rebuilds the list shown to the user from the messages of the current folder, grouped into threads
+// if threaded is set, filtered by the current search, and sorted
+func (c *client) refreshList() {
+	if !c.threaded {
+		c.list = append([]gomua.Mail(nil), c.messages...)
+	} else {
+		c.list = nil
+		for _, t := range gomua.Thread(c.messages) {
+			c.list = append(c.list, t)
+		}
+	}
+	if c.query != nil {
+		// the text index is built on the first search of a folder that needs it, not when the folder is opened
+		if c.text == nil && c.saved == nil && c.query.SearchesText() {
+			c.text, _ = c.indexText(string(c.folderDir()), c.messages, false)
+		}
+		c.query.Index, c.query.Tags = c.text, c.tags
+		c.list = c.query.Filter(c.list)
+	}
+	gomua.Sort(c.list, c.sortKey, c.sortReverse)
+}
+
+// returns the Maildir of the current folder
+func (c *client) folderDir() gomua.Maildir {
+	return gomua.Maildir(c.dir).Folder(c.folder)
+}
+
+// prints the folders of the Maildir with their message counts, marking the current one
+func (c *client) viewFolders(w io.Writer) error {
+	folders, err := gomua.Maildir(c.dir).Folders()
+	if err != nil {
+		return err
+	}
+	for _, f := range folders {
+		mark := "  "
+		if f.Name == c.folder && c.saved == nil {
+			mark = "* "
+		}
+		fmt.Fprintf(w, "%s%s\n", mark, f)
+	}
+
+	// saved searches are run again to count their messages as they are now, scanning each folder once
+	scanned := make(map[string][]gomua.Mail)
+	for _, s := range c.searches {
+		f, err := c.countSearch(s, scanned)
+		if err != nil {
+			return err
+		}
+		mark := "  "
+		if s == c.saved {
+			mark = "* "
+		}
+		fmt.Fprintf(w, "%s%s  search: %s\n", mark, f, s.query)
+	}
+	return nil
+}
+
+// counts the messages matching a saved search, and how many are unread. Unlike runSearch, it leaves new mail
+// in new and reads the text indexes of the folders as they are, without bringing them up to date.
+// scanned holds the messages of the folders already scanned, by directory, and is added to.
+func (c *client) countSearch(s *savedSearch, scanned map[string][]gomua.Mail) (*gomua.Folder, error) {
+	dirs, err := c.searchDirs(s)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &gomua.Folder{Name: s.name}
+	for _, dir := range dirs {
+		msgs, ok := scanned[dir]
+		if !ok {
+			newmail, curmail := c.scanFolder(dir)
+			msgs = append(newmail, curmail...)
+			scanned[dir] = msgs
+		}
+		s.query.Index = nil
+		if s.query.SearchesText() {
+			s.query.Index, _ = cache.OpenTextIndex(filepath.Join(dir, textIndexFile), c.stem)
+		}
+		s.query.Tags = c.tags
+		for _, m := range s.query.Filter(msgs) {
+			f.Total++
+			if m, ok := m.(*gomua.Message); ok && m.Unread() {
+				f.Unread++
+			}
+		}
+	}
+	s.query.Index = nil
+	return f, nil
+}
+
+// switches to the named folder and scans its messages, or opens the saved search of that name if there is no such folder
+func (c *client) changeFolder(name string) error {
+	if name == "" || strings.EqualFold(name, gomua.Inbox) {
+		name = gomua.Inbox
+	}
+	dir := gomua.Maildir(c.dir).Folder(name)
+	if fi, err := os.Stat(string(dir)); err != nil || !fi.IsDir() {
+		if s := c.savedSearch(name); s != nil {
+			return c.openSearch(s)
+		}
+		return fmt.Errorf("no folder %s", name)
+	}
+	c.folder = name
+	c.query = nil
+	c.scanMailDir(string(dir))
+	return nil
+}
+
+// removes a message from the current list, after it has left the folder
+func (c *client) drop(m gomua.Mail) {
+	for i, cm := range c.messages {
+		if cm == m {
+			c.messages = append(c.messages[:i], c.messages[i+1:]...)
+			c.refreshList()
+			return
+		}
+	}
+}
+
+// moves a message to the Trash folder if there is one, otherwise (or if it is already in Trash)
+// flags it Trashed so that expunge will delete it
+func (c *client) trash(m *gomua.Message) error {
+	trash := gomua.Maildir(c.dir).Folder(trashFolder)
+	if _, err := os.Stat(string(trash)); err != nil || c.folder == trashFolder {
+		return m.Flag(gomua.Trashed)
+	}
+	if err := m.MoveTo(trash); err != nil {
+		return err
+	}
+	c.drop(m)
+	return nil
+}
+
+// permanently deletes every message in the current folder flagged Trashed, and returns how many there were
+func (c *client) expunge() (int, error) {
+	var n int
+	for _, msg := range append([]gomua.Mail(nil), c.messages...) {
+		m, ok := msg.(*gomua.Message)
+		if !ok || !m.IsFlagged(gomua.Trashed) {
+			continue
+		}
+		if err := m.Delete(); err != nil {
+			return n, err
+		}
+		c.drop(m)
+		n++
+	}
+	return n, nil
 }
 
-// takes a slice of Messages and prints a numbered list of summaries
-func viewMailList(msgs []gomua.Mail, start int, w io.Writer) {
-	var unread string
+// takes a slice of Mail and prints a numbered list of summaries, with the tags of each message
+func viewMailList(msgs []gomua.Mail, start int, tags *gomua.TagDB, w io.Writer) {
+	var unread, tagged string
 	for i, msg := range msgs {
+		unread, tagged = "", ""
 		switch m := msg.(type) {
 		case *gomua.Message:
 			if m.Unread() {
 				unread = color("(Unread) ", "34")
-			} else {
-				unread = ""
+			}
+			if t := tags.Tags(m); len(t) > 0 {
+				tagged = color(" ["+strings.Join(t, " ")+"]", "32")
+			}
+		case *gomua.MessageThread:
+			if n := m.Unread(); n > 0 {
+				unread = color(fmt.Sprintf("(%d unread) ", n), "34")
 			}
 		}
-		fmt.Fprintf(w, "%d. %s%s\n", i+start+1, unread, msg.Summary())
+		num := fmt.Sprintf("%d. ", i+start+1)
+		// line the rest of a multi-line summary, such as a thread's tree of replies, up under its first line
+		summary := strings.Replace(msg.Summary(), "\n", "\n"+strings.Repeat(" ", len(num)), -1)
+		fmt.Fprintf(w, "%s%s%s%s\n", num, unread, summary, tagged)
 	}
 }
 
-// prints a single mail message to the screen
-func viewMail(msg gomua.Mail, w io.Writer) {
-	fmt.Fprint(w, msg)
+// prints a single mail message to the screen, keeping rendered messages in the body cache.
+// A thread is printed one message after another, in reading order.
+func (c *client) viewMail(msg gomua.Mail, w io.Writer) {
+	if t, ok := msg.(*gomua.MessageThread); ok {
+		for i, m := range t.Messages() {
+			if i > 0 {
+				fmt.Fprintf(w, "\n%s\n", strings.Repeat("-", 72))
+			}
+			c.viewMail(m, w)
+		}
+		return
+	}
+	m, ok := msg.(*gomua.Message)
+	if !ok {
+		fmt.Fprint(w, msg)
+		return
+	}
+
+	key := cache.Key(m.Filename())
+	text, ok := c.bodies.Lookup(key)
+	if !ok {
+		text = m.String()
+		c.bodies.Add(key, text)
+	}
+	fmt.Fprint(w, text)
 
-	switch m := msg.(type) {
-	case *gomua.Message:
-		m.Flag("S")
+	if err := m.Flag(gomua.Seen); err != nil {
+		fmt.Fprintln(w, err)
 	}
 }
 
-// prompts the user for the response content, and sends a reply to the mail
-func replyMessage(old *gomua.Message, user string) (reply *gomua.Message) {
+// prints a numbered list of the attachments of a message
+func viewAttachments(m *gomua.Message, w io.Writer) error {
+	atts, err := m.Attachments()
+	if err != nil {
+		return err
+	}
+	if len(atts) == 0 {
+		fmt.Fprintln(w, "No attachments.")
+	}
+	for i, a := range atts {
+		fmt.Fprintf(w, "%d. %s\n", i+1, a)
+	}
+	return nil
+}
+
+// writes attachment n of a message to path, or to its own name in the current directory if path is empty.
+// If path is a directory, the attachment is saved inside it.
+func saveAttachment(m *gomua.Message, n int, path string) (string, error) {
+	atts, err := m.Attachments()
+	if err != nil {
+		return "", err
+	}
+	if n < 1 || n > len(atts) {
+		return "", fmt.Errorf("no attachment %d, message has %d", n, len(atts))
+	}
+	a := atts[n-1]
+
+	if path == "" {
+		path = a.Name
+	} else if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, a.Name)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, a.Reader()); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// prompts the user for the response content and any attachments, and returns a reply to the mail
+func replyMessage(old *gomua.Message, user string) (reply *gomua.Message, err error) {
 	oldid := old.Header.Get("Message-ID")
 	oldref := old.Header.Get("References")
 
-	to := "To: " + old.Header.Get("From") + "\r\n"
-	from := fmt.Sprintf("From: %s\r\n", user)
-
+	b := gomua.NewBuilder()
+	b.SetHeader("In-Reply-To", oldid)
+	b.SetHeader("References", strings.TrimSpace(oldref+" "+oldid))
+	b.SetHeader("To", old.Header.Get("From"))
+	b.SetHeader("From", user)
 	// TODO: Fix to not duplicate "Re"s.
-	subject := fmt.Sprintf("Subject: Re: %s\r\n", old.Header.Get("Subject"))
-	inreplyto := fmt.Sprintf("In-Reply-To: %s\r\n", oldid)
-	references := fmt.Sprintf("References: %s%s\r\n", oldref, oldid)
+	b.SetHeader("Subject", "Re: "+old.Header.Get("Subject"))
 
 	// TODO: Add time of previous email.
-	content := "\r\n" + old.Header.Get("From") + " wrote:"
+	content := old.From() + " wrote:"
 	quote := bufio.NewScanner(strings.NewReader(old.SanitizeContent()))
 	for quote.Scan() {
 		line := quote.Text()
@@ -150,19 +606,47 @@ func replyMessage(old *gomua.Message, user string) (reply *gomua.Message) {
 		content += "\n" + token + line
 	}
 	content += "\r\n" + gomua.WriteContent(os.Stdin)
+	b.SetText(content)
+	promptAttachments(b, os.Stdin)
 
-	buf := bytes.NewBufferString(inreplyto)
-	buf.WriteString(references)
-	buf.WriteString(to)
-	buf.WriteString(from)
-	buf.WriteString(subject)
-	buf.WriteString(content)
+	return b.Message()
+}
 
-	reply, err := gomua.ReadMessage(bytes.NewReader(buf.Bytes()))
-	if err != nil {
-		log.Fatal(err)
+// prompts the user for the recipient, subject, content and attachments of a new mail, and returns it
+func composeMessage(user string) (*gomua.Message, error) {
+	cli := bufio.NewScanner(os.Stdin)
+
+	b := gomua.NewBuilder()
+	b.SetHeader("From", user)
+	fmt.Print("To: ")
+	cli.Scan()
+	b.SetHeader("To", cli.Text())
+	fmt.Print("Subject: ")
+	cli.Scan()
+	b.SetHeader("Subject", cli.Text())
+
+	b.SetText(gomua.WriteContent(os.Stdin))
+	promptAttachments(b, os.Stdin)
+
+	return b.Message()
+}
+
+// prompts the user for files to attach to a mail, until an empty line is entered
+func promptAttachments(b *gomua.Builder, r io.Reader) {
+	cli := bufio.NewScanner(r)
+	for {
+		fmt.Print("Attach file (empty to finish): ")
+		if !cli.Scan() {
+			return
+		}
+		path := strings.TrimSpace(cli.Text())
+		if path == "" {
+			return
+		}
+		if err := b.AttachFile(path); err != nil {
+			fmt.Println(err)
+		}
 	}
-	return reply
 }
 
 // adds ANSI colors to text
@@ -170,13 +654,33 @@ func color(s string, color string) string {
 	return "\033[" + color + "m" + s + "\033[0m"
 }
 
+// returns the message with the given 1-based list number
+func (c *client) message(num string) (*gomua.Message, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(num))
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(c.list) {
+		return nil, fmt.Errorf("no message %d", n)
+	}
+	m, ok := c.list[n-1].(*gomua.Message)
+	if !ok {
+		return nil, fmt.Errorf("%d is not a single message, use 'threads' to list messages", n)
+	}
+	return m, nil
+}
+
 // helper func to check that view doesn't overflow []. Returns end.
 func (c *client) printList(start, end int) (newstart int, newend int) {
-	if end = start + c.displayN; end > len(c.messages) {
-		end = len(c.messages)
+	// the list may have shrunk since start was returned, as messages were moved or deleted
+	if start > len(c.list) {
+		start = len(c.list)
 	}
-	m := c.messages[start:end]
-	viewMailList(m, start, os.Stdout)
+	if end = start + c.displayN; end > len(c.list) {
+		end = len(c.list)
+	}
+	m := c.list[start:end]
+	viewMailList(m, start, c.tags, os.Stdout)
 	start = end
 	return start, end
 }
@@ -199,33 +703,289 @@ func (c *client) input(exit chan bool) {
 			start, end = c.printList(start, end)
 		case input == "more":
 			start, end = c.printList(start, end)
+		case input == "sort", strings.HasPrefix(input, "sort "):
+			args := strings.Fields(input)
+			if len(args) == 1 {
+				order := c.sortKey.String()
+				if c.sortReverse {
+					order += " reverse"
+				}
+				fmt.Println("sorted by", order)
+				break
+			}
+			key, err := gomua.ParseSortKey(args[1])
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			c.sortKey, c.sortReverse = key, len(args) > 2 && args[2] == "reverse"
+			c.refreshList()
+			start = 0
+			start, end = c.printList(start, end)
+		case input == "search", strings.HasPrefix(input, "search "):
+			var q *gomua.Query
+			if query := strings.TrimSpace(strings.TrimPrefix(input, "search")); query != "" {
+				var err error
+				if q, err = gomua.ParseQuery(query); err != nil {
+					fmt.Println(err)
+					break
+				}
+			}
+			c.query = q
+			c.refreshList()
+			if c.query != nil {
+				fmt.Printf("%d matches for %s\n", len(c.list), c.query)
+			}
+			start = 0
+			start, end = c.printList(start, end)
+		case input == "reindex" && c.saved != nil:
+			msgs, err := c.runSearch(c.saved, true)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			c.messages = msgs
+			c.refreshList()
+			fmt.Printf("Reindexed the folders of %s\n", c.saved.name)
+		case input == "reindex":
+			var n int
+			c.text, n = c.indexText(string(c.folderDir()), c.messages, true)
+			fmt.Printf("Indexed %d messages\n", n)
+		case input == "threads":
+			c.threaded = !c.threaded
+			c.refreshList()
+			start = 0
+			start, end = c.printList(start, end)
 		case strings.HasPrefix(input, "reply"):
-			num, err := strconv.Atoi(strings.TrimPrefix(input, "reply "))
+			old, err := c.message(strings.TrimPrefix(input, "reply"))
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			reply, err := replyMessage(old, c.user)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if err := send.Send(c.configFile, reply); err != nil {
+				fmt.Println(err)
+				break
+			}
+			if err := old.Flag(gomua.Replied); err != nil {
+				fmt.Println(err)
+			}
+		case strings.HasPrefix(input, "attachments "):
+			m, err := c.message(strings.TrimPrefix(input, "attachments "))
 			if err != nil {
 				fmt.Println(err)
+				break
+			}
+			if err := viewAttachments(m, os.Stdout); err != nil {
+				fmt.Println(err)
+			}
+		case strings.HasPrefix(input, "save "):
+			args := strings.Fields(strings.TrimPrefix(input, "save "))
+			if len(args) < 2 {
+				fmt.Println("usage: save # n [path]")
+				break
+			}
+			m, err := c.message(args[0])
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			var path string
+			if len(args) > 2 {
+				path = args[2]
+			}
+			saved, err := saveAttachment(m, n, path)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println("Saved", saved)
+		case input == "folders":
+			if err := c.viewFolders(os.Stdout); err != nil {
+				fmt.Println(err)
+			}
+		case input == "cd", strings.HasPrefix(input, "cd "):
+			if err := c.changeFolder(strings.TrimSpace(strings.TrimPrefix(input, "cd"))); err != nil {
+				fmt.Println(err)
+				break
+			}
+			start = 0
+			fmt.Printf("%s: %d messages\n", c.folder, len(c.messages))
+		case strings.HasPrefix(input, "mkdir "):
+			name := strings.TrimSpace(strings.TrimPrefix(input, "mkdir "))
+			if _, err := gomua.Maildir(c.dir).CreateFolder(name); err != nil {
+				fmt.Println(err)
+			}
+		case strings.HasPrefix(input, "rmdir "):
+			name := strings.TrimSpace(strings.TrimPrefix(input, "rmdir "))
+			if strings.EqualFold(name, c.folder) {
+				fmt.Println("cannot delete the current folder")
+				break
+			}
+			if err := gomua.Maildir(c.dir).DeleteFolder(name); err != nil {
+				fmt.Println(err)
+			}
+		case strings.HasPrefix(input, "mv "), strings.HasPrefix(input, "cp "):
+			args := strings.Fields(input)
+			if len(args) != 3 {
+				fmt.Printf("usage: %s # folder\n", args[0])
+				break
+			}
+			m, err := c.message(args[1])
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			dir := gomua.Maildir(c.dir).Folder(args[2])
+			if _, err := os.Stat(string(dir)); err != nil {
+				fmt.Printf("no folder %s\n", args[2])
+				break
+			}
+			if args[0] == "cp" {
+				_, err = m.CopyTo(dir)
+			} else if err = m.MoveTo(dir); err == nil {
+				c.drop(m)
+			}
+			if err != nil {
+				fmt.Println(err)
+			}
+		case strings.HasPrefix(input, "rm "):
+			m, err := c.message(strings.TrimPrefix(input, "rm "))
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if err := c.trash(m); err != nil {
+				fmt.Println(err)
+			}
+		case input == "expunge":
+			n, err := c.expunge()
+			if err != nil {
+				fmt.Println(err)
+			}
+			fmt.Printf("Deleted %d messages\n", n)
+		case strings.HasPrefix(input, "flag "), strings.HasPrefix(input, "unflag "), strings.HasPrefix(input, "unread "):
+			args := strings.Fields(input)
+			m, err := c.message(args[len(args)-1])
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			switch args[0] {
+			case "flag":
+				err = m.Flag(gomua.Flagged)
+			case "unflag":
+				err = m.Unflag(gomua.Flagged)
+			case "unread":
+				err = m.Unflag(gomua.Seen)
+			}
+			if err != nil {
+				fmt.Println(err)
+			}
+		case strings.HasPrefix(input, "tag "):
+			args := strings.Fields(input)
+			if len(args) < 2 {
+				fmt.Println("usage: tag # [+x] [-y]")
+				break
+			}
+			m, err := c.message(args[1])
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			var add, remove []string
+			for _, t := range args[2:] {
+				switch {
+				case strings.HasPrefix(t, "-"):
+					remove = append(remove, t[1:])
+				default:
+					add = append(add, strings.TrimPrefix(t, "+"))
+				}
+			}
+			if len(add)+len(remove) > 0 {
+				if err := c.tags.Tag(m, add, remove); err != nil {
+					fmt.Println(err)
+					break
+				}
+				if err := c.tags.Save(); err != nil {
+					fmt.Println(err)
+				}
+			}
+			fmt.Printf("tags: %s\n", strings.Join(c.tags.Tags(m), " "))
+		case input == "compose":
+			m, err := composeMessage(c.user)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if err := send.Send(c.configFile, m); err != nil {
+				fmt.Println(err)
 			}
-			old := c.messages[num-1].(*gomua.Message)
-			reply := replyMessage(old, c.user)
-			send.Send(c.configFile, reply)
-			old.Flag("R")
 		case input == "exit", input == "x", input == "quit", input == "q":
 			exit <- true
 		case strings.ContainsAny(input, "01234566789"):
 			num, _ := strconv.Atoi(input)
-			if num <= len(c.messages) && num > 0 {
-				viewMail(c.messages[num-1], os.Stdout)
+			if num <= len(c.list) && num > 0 {
+				c.viewMail(c.list[num-1], os.Stdout)
 			}
 		}
 	}
 }
 
+// rebuilds the text index of every folder, for the reindex command line argument
+func (c *client) reindexAll() error {
+	folders, err := gomua.Maildir(c.dir).Folders()
+	if err != nil {
+		return err
+	}
+	for _, f := range folders {
+		newmail, curmail := c.scanFolder(string(f.Dir))
+		_, n := c.indexText(string(f.Dir), append(newmail, curmail...), true)
+		fmt.Printf("%s: indexed %d messages\n", f.Name, n)
+	}
+	return nil
+}
+
 func help() string {
 	output := fmt.Sprint(
 		"  help                 prints this help\n",
 		"  list                 view the list of mail in your mailbox\n",
 		"  more                 prints more mail listings, if not all were printed previously\n",
-		"  #                    prints the details of the message #\n",
+		"  #                    prints the details of the message #, or every message of thread #\n",
+		"  reindex              rebuilds the index of the words of the messages in this folder, used by searches\n",
+		"  threads              switches the list between single messages and threads\n",
+		"  search [query]       lists only the mail matching query, or all mail again if none is given:\n",
+		"                         words and \"phrases\" are found in the headers and text, or search one field with\n",
+		"                         from: to: subject: body: before:2006-01-02 after:2006-01-02\n",
+		"                         is:unread is:read is:flagged is:replied has:attachment tag:x\n",
+		"                         and join terms with OR, NOT or -, and (parentheses)\n",
+		"  sort key [reverse]   sorts the list by date, from, subject, size, unread or thread, newest date first by default\n",
 		"  reply #              prompts for the text of your reply the message #, then sends it\n",
+		"  folders              lists the folders of your mailbox and the saved searches of your config file,\n",
+		"                         with their unread and total counts\n",
+		"  cd folder            switches to folder or saved search, or back to INBOX if none is given\n",
+		"  mkdir folder         creates a new folder\n",
+		"  rmdir folder         deletes an empty folder\n",
+		"  flag #               flags message # as important\n",
+		"  unflag #             removes the important flag from message #\n",
+		"  unread #             marks message # as unread again\n",
+		"  tag # [+x] [-y]      adds tag x to message # and removes tag y, then prints its tags\n",
+		"  mv # folder          moves message # to folder\n",
+		"  cp # folder          copies message # to folder\n",
+		"  rm #                 moves message # to the Trash folder, or marks it for expunge if there is none\n",
+		"  expunge              permanently deletes the messages marked for deletion in this folder\n",
+		"  compose              prompts for a new mail and any attachments, then sends it\n",
+		"  attachments #        lists the attachments of message #\n",
+		"  save # n [path]      saves attachment n of message # to path, or the current directory\n",
 		"  exit                 exits the program\n")
 
 	return output
@@ -238,7 +998,13 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	client.scanMailDir(client.dir)
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		if err := client.reindexAll(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	client.scanMailDir(string(client.folderDir()))
 
 	exit := make(chan bool, 1)
 	go client.input(exit)
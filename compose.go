@@ -0,0 +1,217 @@
+package gomua
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// base64 bodies are wrapped to this many characters per line (RFC 2045, section 6.8).
+const base64LineLen = 76
+
+// A Builder assembles an outgoing Message from headers, text content, and file attachments.
+// Without attachments the result is a single text/plain Message, otherwise it is multipart/mixed.
+type Builder struct {
+	keys        []string
+	header      map[string]string
+	text        string
+	attachments []*Attachment
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{header: make(map[string]string)}
+}
+
+// SetHeader sets a header of the Message, replacing any previous value.
+// Headers are written in the order they were first set.
+func (b *Builder) SetHeader(key, value string) {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	if _, ok := b.header[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.header[key] = value
+}
+
+// SetText sets the text content of the Message.
+func (b *Builder) SetText(text string) {
+	b.text = text
+}
+
+// Attach adds the content of r as an attachment with the given file name.
+// If mediaType is empty it is detected from the name, then from the content itself.
+func (b *Builder) Attach(name, mediaType string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if mediaType == "" {
+		mediaType = detectMediaType(name, data)
+	}
+
+	b.attachments = append(b.attachments, &Attachment{
+		Name:      name,
+		MediaType: mediaType,
+		Size:      int64(len(data)),
+		part:      &Part{Body: data},
+	})
+	return nil
+}
+
+// AttachFile adds the file at path as an attachment named after its base name.
+func (b *Builder) AttachFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return b.Attach(filepath.Base(path), "", f)
+}
+
+// detectMediaType guesses the media type of an attachment from its file extension, or failing that, its content.
+func detectMediaType(name string, data []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+// Bytes returns the complete Message, ready to send or store.
+func (b *Builder) Bytes() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, k := range b.keys {
+		if k == "Content-Type" || k == "Content-Transfer-Encoding" || k == "Mime-Version" {
+			continue
+		}
+		fmt.Fprintf(buf, "%s: %s%s", k, b.header[k], newline)
+	}
+
+	if len(b.attachments) == 0 {
+		fmt.Fprintf(buf, "Content-Type: text/plain; charset=UTF-8%s%s%s", newline, newline, b.text)
+		return buf.Bytes(), nil
+	}
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+
+	fmt.Fprintf(buf, "MIME-Version: 1.0%s", newline)
+	fmt.Fprintf(buf, "Content-Type: %s%s%s",
+		mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}), newline, newline)
+
+	if err := writeTextPart(mw, b.text); err != nil {
+		return nil, err
+	}
+	for _, a := range b.attachments {
+		if err := writeAttachmentPart(mw, a); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// Message returns the built Message.
+func (b *Builder) Message() (*Message, error) {
+	raw, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return ReadMessage(bytes.NewReader(raw))
+}
+
+// writeTextPart writes the text content of a multipart Message as quoted-printable UTF-8.
+func writeTextPart(mw *multipart.Writer, text string) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", "text/plain; charset=UTF-8")
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, text); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeAttachmentPart writes an attachment as a base64 encoded part.
+func writeAttachmentPart(mw *multipart.Writer, a *Attachment) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", contentTypeHeader(a.MediaType, a.Name))
+	h.Set("Content-Disposition", dispositionHeader(a.Name))
+	h.Set("Content-Transfer-Encoding", "base64")
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	enc := base64.StdEncoding.EncodeToString(a.part.Body)
+	for len(enc) > base64LineLen {
+		if _, err := io.WriteString(w, enc[:base64LineLen]+newline); err != nil {
+			return err
+		}
+		enc = enc[base64LineLen:]
+	}
+	_, err = io.WriteString(w, enc+newline)
+	return err
+}
+
+// contentTypeHeader adds the legacy name parameter to a media type, for clients that ignore Content-Disposition.
+// Non-ASCII names are sent as an RFC 2047 encoded-word, which is what those clients understand.
+func contentTypeHeader(mediaType, name string) string {
+	mt, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		mt, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = name
+	if !isASCII(name) {
+		params["name"] = mime.BEncoding.Encode("UTF-8", name)
+	}
+	return mime.FormatMediaType(mt, params)
+}
+
+// dispositionHeader returns an attachment Content-Disposition for the file name,
+// using RFC 2231 parameter value encoding if the name is not plain ASCII.
+func dispositionHeader(name string) string {
+	if isASCII(name) {
+		return mime.FormatMediaType("attachment", map[string]string{"filename": name})
+	}
+	var enc bytes.Buffer
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			enc.WriteByte(c)
+		} else {
+			fmt.Fprintf(&enc, "%%%02X", c)
+		}
+	}
+	return "attachment; filename*=UTF-8''" + enc.String()
+}
+
+// isASCII checks that s contains only printable ASCII.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
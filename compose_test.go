@@ -0,0 +1,103 @@
+package gomua_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/frenata/gomua"
+)
+
+func Test_BuilderAttachments(t *testing.T) {
+	b := gomua.NewBuilder()
+	b.SetHeader("From", "test1@testing.com")
+	b.SetHeader("To", "test2@testing.com")
+	b.SetHeader("Subject", "files")
+	b.SetText("See attached.\r\n")
+	if err := b.Attach("notes.txt", "", strings.NewReader("some notes")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Attach("Übersicht €.bin", "", strings.NewReader(strings.Repeat("\x00\x01", 100))); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := b.Message()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(m.Header.Get("Content-Type"), "multipart/mixed;") {
+		t.Fatalf("message built as %s", m.Header.Get("Content-Type"))
+	}
+	if text := m.SanitizeContent(); text != "See attached.\r\n" {
+		t.Fatalf("text content read back as %q", text)
+	}
+
+	atts, err := m.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atts) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(atts))
+	}
+	if atts[0].Name != "notes.txt" || !strings.HasPrefix(atts[0].MediaType, "text/plain") {
+		t.Errorf("first attachment read back as %s", atts[0])
+	}
+	if atts[1].Name != "Übersicht €.bin" || atts[1].Size != 200 {
+		t.Errorf("second attachment read back as %s", atts[1])
+	}
+	if b, _ := ioutil.ReadAll(atts[0].Reader()); string(b) != "some notes" {
+		t.Errorf("attachment content read back as %q", b)
+	}
+}
+
+func Test_BuilderAttachmentName(t *testing.T) {
+	name := `日本語 "q".txt`
+	b := gomua.NewBuilder()
+	b.SetText("See attached.\r\n")
+	if err := b.Attach(name, "text/plain", strings.NewReader("some notes")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var found bool
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, params, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatalf("Content-Type %q: %v", p.Header.Get("Content-Type"), err)
+		}
+		if params["name"] == "" {
+			continue
+		}
+		found = true
+		if got, err := new(mime.WordDecoder).DecodeHeader(params["name"]); got != name || err != nil {
+			t.Errorf("name parameter read back as %q, %v", got, err)
+		}
+	}
+	if !found {
+		t.Errorf("no attachment with a name parameter")
+	}
+}
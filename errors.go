@@ -0,0 +1,20 @@
+package gomua
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoMessageID is returned when tagging a Message that has no Message-ID to keep its tags under.
+var ErrNoMessageID = errors.New("message has no Message-ID")
+
+// A FilenameError reports a Message file whose name is not a valid Maildir name, for instance
+// because it lacks the info section that carries its flags.
+type FilenameError struct {
+	Filename string
+	Reason   string
+}
+
+func (e *FilenameError) Error() string {
+	return fmt.Sprintf("malformed filename %s: %s", e.Filename, e.Reason)
+}
@@ -0,0 +1,161 @@
+package gomua
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Inbox is the name of the root folder of a Maildir++ hierarchy.
+const Inbox = "INBOX"
+
+// maildirfolderFile marks a directory as a Maildir++ subfolder.
+const maildirfolderFile = "maildirfolder"
+
+// A Folder is one mailbox of a Maildir++ hierarchy, with its message counts at the time it was listed.
+// Subfolder names are dot separated, like "Archive.2025", and the root is named Inbox.
+type Folder struct {
+	Name   string
+	Dir    Maildir
+	Unread int
+	Total  int
+}
+
+// String describes the Folder on one line.
+func (f *Folder) String() string {
+	return fmt.Sprintf("%s (%d unread, %d total)", f.Name, f.Unread, f.Total)
+}
+
+// Folders returns the root of the Maildir and each of its Maildir++ subfolders, sorted by name.
+func (d Maildir) Folders() ([]*Folder, error) {
+	infos, err := ioutil.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{Inbox}
+	for _, fi := range infos {
+		n := fi.Name()
+		if fi.IsDir() && len(n) > 1 && n[0] == '.' && n != ".." && isFolder(filepath.Join(string(d), n)) {
+			names = append(names, n[1:])
+		}
+	}
+	sort.Strings(names[1:])
+
+	var folders []*Folder
+	for _, n := range names {
+		f := &Folder{Name: n, Dir: d.Folder(n)}
+		if f.Unread, f.Total, err = f.Dir.count(); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, nil
+}
+
+// isFolder checks if a directory is a Maildir++ subfolder rather than, say, the database of another tool:
+// it must be marked with a maildirfolder file, or hold a cur or new directory.
+func isFolder(dir string) bool {
+	for _, name := range []string{maildirfolderFile, curDir, newDir} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Folder returns the Maildir of the named subfolder, which need not exist yet.
+func (d Maildir) Folder(name string) Maildir {
+	if name == "" || strings.EqualFold(name, Inbox) {
+		return d
+	}
+	return Maildir(filepath.Join(string(d), "."+name))
+}
+
+// CreateFolder makes a new Maildir++ subfolder and returns its Maildir.
+func (d Maildir) CreateFolder(name string) (Maildir, error) {
+	if err := validFolderName(name); err != nil {
+		return "", err
+	}
+
+	f := d.Folder(name)
+	if _, err := os.Stat(string(f)); err == nil {
+		return "", fmt.Errorf("folder %s already exists", name)
+	}
+	if err := f.Create(); err != nil {
+		return "", err
+	}
+	return f, ioutil.WriteFile(filepath.Join(string(f), maildirfolderFile), nil, 0600)
+}
+
+// DeleteFolder removes an empty Maildir++ subfolder.
+// Folders that still hold messages are not deleted, and neither is the root. A missing folder is an error.
+func (d Maildir) DeleteFolder(name string) error {
+	if err := validFolderName(name); err != nil {
+		return err
+	}
+
+	f := d.Folder(name)
+	if fi, err := os.Stat(string(f)); err != nil || !fi.IsDir() {
+		return fmt.Errorf("no folder %s", name)
+	}
+	_, total, err := f.count()
+	if err != nil {
+		return err
+	}
+	if total != 0 {
+		return fmt.Errorf("folder %s still holds %d messages", name, total)
+	}
+	return os.RemoveAll(string(f))
+}
+
+// validFolderName checks that a folder name can be used as a Maildir++ subfolder.
+func validFolderName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty folder name")
+	case strings.EqualFold(name, Inbox):
+		return fmt.Errorf("%s is the root folder", Inbox)
+	case strings.ContainsAny(name, "/\\"), strings.HasPrefix(name, "."), strings.HasSuffix(name, "."), strings.Contains(name, ".."):
+		return fmt.Errorf("invalid folder name %q", name)
+	}
+	return nil
+}
+
+// count returns the number of unread and total messages in the Maildir.
+// Everything in new is unread, as is everything in cur without the Seen flag.
+func (d Maildir) count() (unread, total int, err error) {
+	for _, sub := range []string{newDir, curDir} {
+		infos, err := ioutil.ReadDir(filepath.Join(string(d), sub))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+
+		for _, fi := range infos {
+			if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			total++
+			if sub == newDir || !strings.Contains(infoFlags(fi.Name()), Seen) {
+				unread++
+			}
+		}
+	}
+	return unread, total, nil
+}
+
+// infoFlags returns the flags in the info section of a Maildir file name, or an empty string if there is none.
+func infoFlags(name string) string {
+	i := strings.LastIndex(name, infotag)
+	if i < 0 {
+		return ""
+	}
+	return name[i+len(infotag):]
+}
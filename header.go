@@ -0,0 +1,128 @@
+package gomua
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// wordDecoder decodes RFC 2047 encoded-words, handing any charset Go does not know itself to the charset registry.
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// charsetReader adapts DecodeCharset to the mime.WordDecoder CharsetReader hook.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	d, err := DecodeCharset(charset, b)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(d), nil
+}
+
+// DecodedHeader returns the value of a header ready for display: RFC 2047 encoded-words are decoded,
+// and raw 8-bit values are read in the charset of the Message.
+// Encoded-words that cannot be decoded are left as they are.
+func (m *Message) DecodedHeader(key string) string {
+	v := m.Header.Get(key)
+	if d, err := wordDecoder.DecodeHeader(v); err == nil {
+		v = d
+	}
+	if utf8.ValidString(v) {
+		return v
+	}
+	_, params := parseMediaHeader(m.Header.Get("Content-Type"))
+	return toUTF8(params["charset"], []byte(v))
+}
+
+// Subject returns the decoded Subject header.
+func (m *Message) Subject() string { return m.DecodedHeader("Subject") }
+
+// From returns the decoded From header.
+func (m *Message) From() string { return m.DecodedHeader("From") }
+
+// To returns the decoded To header.
+func (m *Message) To() string { return m.DecodedHeader("To") }
+
+// MessageID returns the message identifier in the Message-ID header, with its angle brackets,
+// or "" if there is none.
+func (m *Message) MessageID() string {
+	if ids := msgIDPattern.FindAllString(m.Header.Get("Message-Id"), 1); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// dateLayouts are the malformed Date headers seen in the wild that mail.ParseDate rejects.
+var dateLayouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 2006 15:04:05",
+	"Jan 2 15:04:05 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+var (
+	// dateComment matches a parenthesized comment, such as the zone name after the offset.
+	dateComment = regexp.MustCompile(`\([^)]*\)`)
+	// dateWeekday matches a leading day of the week, with or without its comma.
+	dateWeekday = regexp.MustCompile(`^(?i)(mon|tue|wed|thu|fri|sat|sun)[a-z]*,?\s+`)
+	// dateGMTOffset matches a zone written as an offset from GMT or UTC, as in GMT+0100.
+	dateGMTOffset = regexp.MustCompile(`(?i)\b(GMT|UTC)([+-]\d{4})\b`)
+)
+
+// parseDate reads a date as mail.ParseDate does, then tries the malformed layouts it rejects.
+func parseDate(s string) (time.Time, error) {
+	d, err := mail.ParseDate(s)
+	if err == nil {
+		return d, nil
+	}
+
+	s = dateComment.ReplaceAllString(s, "")
+	s = dateWeekday.ReplaceAllString(strings.TrimSpace(s), "")
+	s = dateGMTOffset.ReplaceAllString(s, "$2")
+	s = strings.Join(strings.Fields(strings.Replace(s, ",", " ", -1)), " ")
+	for _, layout := range dateLayouts {
+		if d, lerr := time.Parse(layout, s); lerr == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// Date returns when the Message was sent, from its Date header. If the header is missing or cannot be
+// read, the Message is dated by the newest Received header, as when it was delivered, and failing that by
+// the modification time of its file. Messages with no date at all have the zero time.
+func (m *Message) Date() time.Time {
+	if d, err := parseDate(m.Header.Get("Date")); err == nil {
+		return d
+	}
+	// the first Received header is the last added, by the final server
+	if received := m.Header["Received"]; len(received) > 0 {
+		if i := strings.LastIndex(received[0], ";"); i >= 0 {
+			if d, err := parseDate(received[0][i+1:]); err == nil {
+				return d
+			}
+		}
+	}
+	if m.filename != "" {
+		if fi, err := os.Stat(m.filename); err == nil {
+			return fi.ModTime()
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,32 @@
+package gomua_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/frenata/gomua"
+)
+
+func Test_DecodedHeader(t *testing.T) {
+	msg := "From: =?ISO-8859-1?Q?Andr=E9?= <andre@testing.com>\r\n" +
+		"To: =?windows-1252?Q?=93Team=94?= <team@testing.com>\r\n" +
+		"Subject: =?UTF-8?B?SGVsbG8gd29ybGQg4pyT?=\r\n" +
+		"\r\nbody\r\n"
+	m, err := gomua.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.Subject(); got != "Hello world ✓" {
+		t.Errorf("subject decoded as %q", got)
+	}
+	if got := m.From(); got != "André <andre@testing.com>" {
+		t.Errorf("from decoded as %q", got)
+	}
+	if got := m.To(); got != "“Team” <team@testing.com>" {
+		t.Errorf("to decoded as %q", got)
+	}
+	if sum := m.Summary(); !strings.Contains(sum, "Hello world ✓") {
+		t.Errorf("summary does not use decoded subject: %q", sum)
+	}
+}
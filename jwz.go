@@ -0,0 +1,271 @@
+package gomua
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// msgIDPattern matches one message identifier in a Message-ID, In-Reply-To or References header.
+var msgIDPattern = regexp.MustCompile(`<[^<>\s]+>`)
+
+// A container is one node of the threading tree. It holds a Message, or is empty if the Message
+// is only known from the references of others.
+type container struct {
+	id       string
+	subject  string // used to key empty roots joined by subject
+	msg      *Message
+	parent   *container
+	children []*container
+}
+
+// A threader links Messages into containers by their message identifiers.
+type threader struct {
+	ids   map[string]*container
+	order []*container // in creation order, so the result does not depend on map iteration
+	anon  int
+}
+
+func newThreader() *threader {
+	return &threader{ids: make(map[string]*container)}
+}
+
+// container returns the container for a message identifier, creating an empty one if needed.
+func (th *threader) container(id string) *container {
+	c, ok := th.ids[id]
+	if !ok {
+		c = &container{id: id}
+		th.ids[id] = c
+		th.order = append(th.order, c)
+	}
+	return c
+}
+
+// add places a Message in its container, and links the containers of everything it references
+// into a chain of parents, oldest first.
+func (th *threader) add(m *Message) {
+	id := m.MessageID()
+	if id == "" || th.ids[id] != nil && th.ids[id].msg != nil {
+		// a missing or duplicate identifier gets a unique one of its own
+		th.anon++
+		id = "<anonymous." + strconv.Itoa(th.anon) + ">"
+	}
+	c := th.container(id)
+	c.msg = m
+
+	var parent *container
+	for _, ref := range references(m) {
+		rc := th.container(ref)
+		if parent != nil && rc.parent == nil && !rc.isAncestorOf(parent) && rc != parent {
+			parent.adopt(rc)
+		}
+		parent = rc
+	}
+
+	// the message's own references are the best account of its parent
+	if c.parent != nil {
+		c.parent.disown(c)
+	}
+	if parent != nil && parent != c && !c.isAncestorOf(parent) {
+		parent.adopt(c)
+	}
+}
+
+// references returns the message identifiers a Message replies to, oldest first.
+// In-Reply-To is used as the parent if References does not end with it.
+func references(m *Message) []string {
+	refs := msgIDPattern.FindAllString(m.Header.Get("References"), -1)
+	irt := msgIDPattern.FindAllString(m.Header.Get("In-Reply-To"), 1)
+	if len(irt) > 0 && (len(refs) == 0 || refs[len(refs)-1] != irt[0]) {
+		refs = append(refs, irt[0])
+	}
+	return refs
+}
+
+// roots returns every container without a parent, in creation order.
+func (th *threader) roots() []*container {
+	var roots []*container
+	for _, c := range th.order {
+		if c.parent == nil {
+			roots = append(roots, c)
+		}
+	}
+	return roots
+}
+
+// adopt makes child a child of c.
+func (c *container) adopt(child *container) {
+	child.parent = c
+	c.children = append(c.children, child)
+}
+
+// disown removes child from the children of c.
+func (c *container) disown(child *container) {
+	for i, cc := range c.children {
+		if cc == child {
+			c.children = append(c.children[:i], c.children[i+1:]...)
+			break
+		}
+	}
+	child.parent = nil
+}
+
+// isAncestorOf checks if c is o, or any parent of o.
+func (c *container) isAncestorOf(o *container) bool {
+	for ; o != nil; o = o.parent {
+		if o == c {
+			return true
+		}
+	}
+	return false
+}
+
+// node converts c and everything below it into ThreadNodes.
+func (c *container) node(parent *ThreadNode) *ThreadNode {
+	n := &ThreadNode{msg: c.msg, parent: parent}
+	for _, child := range c.children {
+		n.children = append(n.children, child.node(n))
+	}
+	return n
+}
+
+// key returns the key of the thread rooted at c.
+func (c *container) key() string {
+	if c.id != "" {
+		return c.id
+	}
+	return "subject:" + c.subject
+}
+
+// pruneEmpty removes empty containers from a list of siblings, replacing each with its children.
+// Among the roots (parent nil), an empty container with several children is kept, to hold them together.
+func pruneEmpty(siblings []*container, parent *container) []*container {
+	var kept []*container
+	for _, c := range siblings {
+		c.children = pruneEmpty(c.children, c)
+		switch {
+		case c.msg == nil && len(c.children) == 0:
+		case c.msg == nil && (parent != nil || len(c.children) == 1):
+			for _, child := range c.children {
+				child.parent = parent
+			}
+			kept = append(kept, c.children...)
+		default:
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// groupBySubject joins roots that share a subject, as happens when the references linking them are lost.
+func groupBySubject(roots []*container) []*container {
+	bySubject := make(map[string]*container)
+	for _, c := range roots {
+		subj := c.baseSubject()
+		if subj == "" {
+			continue
+		}
+		old, ok := bySubject[subj]
+		if !ok || c.msg == nil && old.msg != nil || old.msg != nil && c.msg != nil && old.isReply() && !c.isReply() {
+			bySubject[subj] = c
+		}
+	}
+
+	var grouped []*container
+	for _, c := range roots {
+		if c.parent != nil {
+			// already placed under a root that came earlier
+			continue
+		}
+		subj := c.baseSubject()
+		top, ok := bySubject[subj]
+		if subj == "" || !ok || top == c {
+			grouped = append(grouped, c)
+			continue
+		}
+
+		switch {
+		case top.msg == nil && c.msg == nil:
+			for _, child := range c.children {
+				top.adopt(child)
+			}
+			c.children = nil
+		case top.msg == nil:
+			top.adopt(c)
+		case top.isReply() == c.isReply():
+			// neither is the original message: hold both under a new empty root
+			holder := &container{subject: subj}
+			idx := indexOf(grouped, top)
+			holder.adopt(top)
+			holder.adopt(c)
+			bySubject[subj] = holder
+			if idx >= 0 {
+				grouped[idx] = holder
+			} else {
+				// top comes later in roots, and will be skipped as it now has a parent
+				grouped = append(grouped, holder)
+			}
+		default:
+			top.adopt(c)
+		}
+	}
+
+	var result []*container
+	for _, c := range grouped {
+		if c.parent == nil {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func indexOf(cs []*container, c *container) int {
+	for i, cc := range cs {
+		if cc == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// baseSubject returns the subject of the thread rooted at c, without reply and forward prefixes.
+func (c *container) baseSubject() string {
+	m := c.msg
+	if m == nil && len(c.children) > 0 {
+		m = c.children[0].msg
+	}
+	if m == nil {
+		return ""
+	}
+	base, _ := baseSubject(m.Subject())
+	return base
+}
+
+// isReply checks if the subject of the Message in c marks it as a reply or forward.
+func (c *container) isReply() bool {
+	if c.msg == nil {
+		return false
+	}
+	_, reply := baseSubject(c.msg.Subject())
+	return reply
+}
+
+// subjectPrefix matches one reply or forward prefix, or mailing list tag, at the start of a subject.
+var subjectPrefix = regexp.MustCompile(`(?i)^\s*(((re|fwd?|aw|sv|antw)(\[\d+\])?\s*:)|\[[^\]]*\])\s*`)
+
+// baseSubject strips reply and forward prefixes and list tags from a subject, and reports whether there
+// was a reply or forward prefix. The result is lower case, for comparison.
+func baseSubject(subject string) (string, bool) {
+	reply := false
+	for {
+		loc := subjectPrefix.FindStringIndex(subject)
+		if loc == nil || loc[1] == 0 {
+			break
+		}
+		if !strings.HasPrefix(strings.TrimSpace(subject), "[") {
+			reply = true
+		}
+		subject = subject[loc[1]:]
+	}
+	return strings.ToLower(strings.TrimSpace(subject)), reply
+}
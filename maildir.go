@@ -0,0 +1,93 @@
+package gomua
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Maildir subdirectories
+const (
+	tmpDir = "tmp"
+	newDir = "new"
+	curDir = "cur"
+)
+
+// deliveries counts the messages delivered by this process, to keep unique names unique within one microsecond.
+var deliveries uint64
+
+// A Maildir is the root directory of a mailbox in Maildir format, holding the tmp, new and cur subdirectories.
+type Maildir string
+
+// Create makes the Maildir and its tmp, new and cur subdirectories, if they do not already exist.
+func (d Maildir) Create() error {
+	for _, sub := range []string{tmpDir, newDir, curDir} {
+		if err := os.MkdirAll(filepath.Join(string(d), sub), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver writes a new message read from r into the Maildir, and returns the path of the delivered file.
+// The message is written and synced to disk in tmp before it is moved into new, so a reader of new
+// never sees a partial message.
+func (d Maildir) Deliver(r io.Reader) (string, error) {
+	return d.deliver(r, newDir, "")
+}
+
+// Store writes a message read from r directly into cur with the given flags, and returns the path of the stored file.
+// This suits messages that have already been read, such as drafts and sent copies.
+func (d Maildir) Store(r io.Reader, flags string) (string, error) {
+	return d.deliver(r, curDir, infotag+sortFlags(flags))
+}
+
+// deliver writes r to tmp, then renames it into the subdirectory sub with the given info suffix.
+func (d Maildir) deliver(r io.Reader, sub, info string) (string, error) {
+	if err := d.Create(); err != nil {
+		return "", err
+	}
+
+	name := uniqueName(time.Now())
+	tmp := filepath.Join(string(d), tmpDir, name)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	dst := filepath.Join(string(d), sub, name+info)
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return dst, nil
+}
+
+// uniqueName returns a Maildir file name in the form time.MusecPpidQcounter.hostname,
+// as described at https://cr.yp.to/proto/maildir.html.
+func uniqueName(t time.Time) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+
+	n := atomic.AddUint64(&deliveries, 1)
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", t.Unix(), t.Nanosecond()/1000, os.Getpid(), n, host)
+}
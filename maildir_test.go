@@ -0,0 +1,267 @@
+package gomua_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frenata/gomua"
+	"github.com/frenata/gomua/cache"
+)
+
+func Test_MaildirDeliver(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(filepath.Join(root, "Mail"))
+
+	first, err := md.Deliver(strings.NewReader(msgStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := md.Deliver(strings.NewReader(msgStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("two deliveries share the name %s", first)
+	}
+	if filepath.Dir(first) != filepath.Join(string(md), "new") {
+		t.Fatalf("message delivered to %s instead of new", first)
+	}
+	if b, _ := ioutil.ReadFile(first); string(b) != msgStr {
+		t.Fatalf("delivered message reads back as %q", b)
+	}
+	if tmp, _ := ioutil.ReadDir(filepath.Join(string(md), "tmp")); len(tmp) != 0 {
+		t.Fatalf("%d files left behind in tmp", len(tmp))
+	}
+
+	sent, err := md.Store(strings.NewReader(msgStr), "SR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(sent) != filepath.Join(string(md), "cur") || !strings.HasSuffix(sent, ":2,RS") {
+		t.Fatalf("message stored as %s", sent)
+	}
+}
+
+func Test_MaildirFolders(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(root)
+
+	if _, err := md.Deliver(strings.NewReader(msgStr)); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := md.CreateFolder("Archive.2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := md.CreateFolder("Sent"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := md.CreateFolder("../escape"); err == nil {
+		t.Fatal("folder name with a path separator was accepted")
+	}
+	archive.Store(strings.NewReader(msgStr), gomua.Seen)
+	archive.Store(strings.NewReader(msgStr), "")
+	// the dot directory of another tool is not a folder
+	if err := os.MkdirAll(filepath.Join(root, ".notmuch", "xapian"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	folders, err := md.Folders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range folders {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"INBOX (1 unread, 1 total)",
+		"Archive.2025 (1 unread, 2 total)",
+		"Sent (0 unread, 0 total)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("folders listed as\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if err := md.DeleteFolder("Archive.2025"); err == nil {
+		t.Fatal("folder holding messages was deleted")
+	}
+	if err := md.DeleteFolder("Sent"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(string(md.Folder("Sent"))); !os.IsNotExist(err) {
+		t.Fatal("deleted folder still exists")
+	}
+	if err := md.DeleteFolder("Sent"); err == nil {
+		t.Fatal("missing folder was deleted without an error")
+	}
+}
+
+func Test_MessageMoveCopyDelete(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(root)
+	archive, err := md.CreateFolder("Archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := md.Store(strings.NewReader(msgStr), "RS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := gomua.Scan(path)[0].(*gomua.Message)
+
+	if err := m.MoveTo(archive); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(m.Filename()) != filepath.Join(string(archive), "cur") || !strings.HasSuffix(m.Filename(), ":2,RS") {
+		t.Fatalf("moved message named %s", m.Filename())
+	}
+
+	cp, err := m.CopyTo(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(cp, ":2,RS") {
+		t.Fatalf("copied message named %s", cp)
+	}
+
+	if err := m.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(m.Filename()); !os.IsNotExist(err) {
+		t.Fatal("deleted message still exists")
+	}
+	if _, err := os.Stat(cp); err != nil {
+		t.Fatal("deleting the original removed the copy")
+	}
+}
+
+func Test_ScanPathErrors(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	ioutil.WriteFile(filepath.Join(root, "good"), []byte(msgStr), 0600)
+	ioutil.WriteFile(filepath.Join(root, "empty"), nil, 0600)
+	os.Mkdir(filepath.Join(root, "sub"), 0700)
+
+	res := gomua.ScanPath(root)
+	if len(res.Messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(res.Messages))
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Path != filepath.Join(root, "empty") {
+		t.Fatalf("expected one error for the empty file, got %v", res.Errors)
+	}
+
+	res = gomua.ScanPath(filepath.Join(root, "missing"))
+	if len(res.Messages) != 0 || len(res.Errors) != 1 {
+		t.Fatalf("scanning a missing path returned %d messages and %d errors", len(res.Messages), len(res.Errors))
+	}
+}
+
+func Test_ScannerOrder(t *testing.T) {
+	const dir = "./cmd/mua/testmaildir"
+	want := gomua.ScanPath(dir)
+	if len(want.Messages) == 0 {
+		t.Fatal("no messages scanned")
+	}
+
+	for _, workers := range []int{1, 3, 16} {
+		got, err := (&gomua.Scanner{Workers: workers}).Scan(context.Background(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Messages) != len(want.Messages) {
+			t.Fatalf("%d workers found %d messages, expected %d", workers, len(got.Messages), len(want.Messages))
+		}
+		for i, m := range got.Messages {
+			if m.(*gomua.Message).Filename() != want.Messages[i].(*gomua.Message).Filename() {
+				t.Fatalf("%d workers returned messages out of order at %d", workers, i)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := new(gomua.Scanner).Scan(ctx, dir); err != context.Canceled {
+		t.Fatalf("cancelled scan returned %v", err)
+	}
+}
+
+func Test_ScannerIndex(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(root)
+	if err := md.Create(); err != nil {
+		t.Fatal(err)
+	}
+	path, err := md.Store(strings.NewReader("Subject: aaaa\r\n\r\nbody one\r\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func() *gomua.Message {
+		ix, err := cache.OpenIndex(filepath.Join(root, "index"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := (&gomua.Scanner{Index: ix}).Scan(context.Background(), filepath.Join(root, "cur"))
+		if err != nil || len(res.Messages) != 1 {
+			t.Fatalf("scan found %d messages, %v", len(res.Messages), err)
+		}
+		if err := ix.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return res.Messages[0].(*gomua.Message)
+	}
+	if err := scan().Flag(gomua.Seen); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the file behind the index's back, keeping its size and modification time,
+	// so that only a scan that reads the file again sees the new subject
+	renamed := path + "S"
+	if err := ioutil.WriteFile(renamed, []byte("Subject: bbbb\r\n\r\nbody two\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(renamed, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	m := scan()
+	if m.Filename() != renamed {
+		t.Fatalf("rescanned message is %s, expected %s", m.Filename(), renamed)
+	}
+	if subject := m.Subject(); subject != "aaaa" {
+		t.Errorf("subject read as %q, expected the indexed %q", subject, "aaaa")
+	}
+	if content := m.Content(); content != "body two\r\n" {
+		t.Errorf("content read as %q from the renamed file", content)
+	}
+}
@@ -2,11 +2,10 @@ package gomua
 
 import (
 	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/mail"
 	"os"
 	"path/filepath"
@@ -36,6 +35,20 @@ func (a byLetter) Len() int           { return len(a) }
 func (a byLetter) Less(i, j int) bool { return a[i] < a[j] }
 func (a byLetter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
+// sortFlags returns Maildir flags in the ASCII order the Maildir spec requires, each only once.
+func sortFlags(flags string) string {
+	bflags := []byte(flags)
+	sort.Sort(byLetter(bflags))
+
+	var uniq []byte
+	for i, f := range bflags {
+		if i == 0 || f != bflags[i-1] {
+			uniq = append(uniq, f)
+		}
+	}
+	return string(uniq)
+}
+
 // Mail interface defines mail to be read.
 // This can be a threaded list of messages, or a single message.
 //
@@ -54,7 +67,7 @@ type Mail interface {
 }
 
 // Message implmenets the Mail interface. It holds an embedded mail.Message that it will store the Body
-// of on demand.
+// of on demand. Messages scanned from files hold only their headers, and have no Body until stored.
 type Message struct {
 	*mail.Message
 	isStored bool
@@ -64,10 +77,10 @@ type Message struct {
 
 // String prints a Message: some basic headers and the Message content.
 func (m *Message) String() string {
-	var output = fmt.Sprintf("From: %v%s", m.Header.Get("From"), newline) +
-		fmt.Sprintf("To: %v%s", m.Header.Get("To"), newline) +
-		fmt.Sprintf("Date: %v%s", m.Header.Get("Date"), newline) +
-		fmt.Sprintf("Subject: %v%s", m.Header.Get("Subject"), newline)
+	var output = fmt.Sprintf("From: %v%s", m.From(), newline) +
+		fmt.Sprintf("To: %v%s", m.To(), newline) +
+		fmt.Sprintf("Date: %v%s", m.DecodedHeader("Date"), newline) +
+		fmt.Sprintf("Subject: %v%s", m.Subject(), newline)
 
 		//output += fmt.Sprintf("\n%s\n", m.Content)
 	output += fmt.Sprintf("%s%s", newline, m.SanitizeContent())
@@ -77,8 +90,8 @@ func (m *Message) String() string {
 
 // Summary prints a one line summary of the Message content.
 func (m *Message) Summary() string {
-	subject := m.Header.Get("Subject")
-	from := m.Header.Get("From")
+	subject := m.Subject()
+	from := m.From()
 	return fmt.Sprintf("%s from %s", color(subject, "31"), color(from, "33"))
 }
 
@@ -90,24 +103,74 @@ func color(s string, color string) string {
 // Filename returns Message's current filename.
 func (m *Message) Filename() string { return m.filename }
 
-// Content stores the mesasage content if it hasn't yet been stored, then returns the content.
+// Size returns the size of the Message in bytes: that of its file, or of its content if it has none.
+func (m *Message) Size() int64 {
+	if m.filename != "" {
+		if fi, err := os.Stat(m.filename); err == nil {
+			return fi.Size()
+		}
+	}
+	return int64(len(m.Content()))
+}
+
+// Content returns the message content, storing it first if it has not yet been stored.
+// Messages scanned from files that have not been stored read their content from the file
+// every time instead, so that only the messages in use hold their content in memory.
+// The content is raw: use MIME or SanitizeContent to read it with transfer encodings decoded.
+// If the content cannot be read, whatever was read is returned; call Store to get the error.
 func (m *Message) Content() string {
+	if !m.isStored && m.onDisk() {
+		c, _ := m.readContent()
+		return c
+	}
 	if !m.isStored {
 		m.Store()
 	}
 	return m.content
 }
 
-// Store reads from the io.Reader in the embedded mail.Message.Body, then permanently stores this content
-// in the Message struct.
-func (m *Message) Store() {
+// Store reads the content from the io.Reader in the embedded mail.Message.Body, or from the file of a
+// scanned Message, then permanently stores this content in the Message struct.
+func (m *Message) Store() error {
+	if m.onDisk() {
+		c, err := m.readContent()
+		m.content = c
+		m.isStored = err == nil
+		return err
+	}
+
+	if m.Message == nil || m.Message.Body == nil {
+		return errors.New("message has no body to read")
+	}
 	b, err := ioutil.ReadAll(m.Message.Body)
+	m.content = string(b)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	m.content = string(b)
 	m.isStored = true
+	return nil
+}
+
+// onDisk returns true if the Message content is left in its file until needed.
+func (m *Message) onDisk() bool {
+	return m.filename != "" && m.Message != nil && m.Message.Body == nil
+}
+
+// readContent reads the content of the Message from its file, skipping the headers.
+func (m *Message) readContent() (string, error) {
+	f, err := os.Open(m.filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(bufio.NewReader(f))
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadAll(msg.Body)
+	return string(b), err
 }
 
 // ReadMessage reads a Message from the designated Reader, and returns the Message.
@@ -121,9 +184,13 @@ func ReadMessage(r io.Reader) (*Message, error) {
 }
 
 // Move moves the Message to a new directory and appends the Info pre flag, if it has not been previously.
+// Any flags already set on the Message are kept.
 func (m *Message) Move(newpath string) error {
 	name := filepath.Base(m.Filename())
-	newname := filepath.Join(newpath, name) + infotag
+	if !strings.Contains(name, infotag) {
+		name += infotag
+	}
+	newname := filepath.Join(newpath, name)
 	err := os.Rename(m.filename, newname)
 	if err != nil {
 		return err
@@ -132,86 +199,106 @@ func (m *Message) Move(newpath string) error {
 	return nil
 }
 
-// Flag sets a filename flag on the message.
-func (m *Message) Flag(flag string) {
-	s := strings.Split(m.filename, infotag)
-	if len(s) != 2 {
-		log.Fatal(fmt.Errorf("filename %s does not contain '%s'", m.Filename(), infotag))
-	}
-	name := s[0]
-	flags := s[1]
-
-	if !strings.Contains(flags, flag) {
-		//if flags[len(flags)-1] != ',' {
-		//	flags += ","
-		//}
-		flags += flag
-
-		bflags := []byte(flags)
-		sort.Sort(byLetter(bflags))
-		flags = string(bflags)
-
-		newname := name + infotag + flags
-		os.Rename(m.filename, newname)
-		m.filename = newname
+// MoveTo moves the Message into the cur directory of another Maildir, keeping its flags.
+func (m *Message) MoveTo(d Maildir) error {
+	if err := d.Create(); err != nil {
+		return err
 	}
+	return m.Move(filepath.Join(string(d), curDir))
 }
 
-// IsFlagged checks if the filename of the message is flagged with the given flag.
-func (m *Message) IsFlagged(flag string) bool {
-	s := strings.Split(m.filename, infotag)
-	if len(s) != 2 {
-		log.Fatal(fmt.Errorf("filename %s does not contain '%s'", m.Filename(), infotag))
+// CopyTo stores a copy of the Message in another Maildir with the same flags, and returns the path of the copy.
+func (m *Message) CopyTo(d Maildir) (string, error) {
+	f, err := os.Open(m.filename)
+	if err != nil {
+		return "", err
 	}
-	flags := s[1]
+	defer f.Close()
 
-	return strings.Contains(flags, flag)
+	return d.Store(f, infoFlags(filepath.Base(m.filename)))
 }
 
-// Unread returns true if the message is unread.
-func (m *Message) Unread() bool {
-	return !m.IsFlagged(Seen)
+// Delete permanently removes the Message file.
+func (m *Message) Delete() error {
+	return os.Remove(m.filename)
 }
 
-// SanitizeContent returns only text/plain content portions of the email.
-// --Probably not fully working.--
-func (m *Message) SanitizeContent() string {
-	var bound string
-	var boundB = false
-	t := m.Header.Get("Content-Type")
-	if strings.Contains(t, "boundary=") {
-		bs := strings.Split(t, "boundary=")
-		bound = "--" + bs[1]
-		boundB = true
+// info splits the filename of the message into the part before the Info pre flag and the flags after it.
+func (m *Message) info() (name, flags string, err error) {
+	i := strings.LastIndex(m.filename, infotag)
+	if i < 0 || strings.ContainsRune(m.filename[i:], filepath.Separator) {
+		return "", "", &FilenameError{Filename: m.filename, Reason: fmt.Sprintf("does not contain '%s'", infotag)}
 	}
+	return m.filename[:i], m.filename[i+len(infotag):], nil
+}
 
-	raw := bufio.NewScanner(strings.NewReader(m.Content()))
-	buf := new(bytes.Buffer)
-	var write = true
-	for raw.Scan() {
-		line := raw.Text()
-		if strings.Contains(line, "Content-Type:") {
-			write = false
-		}
+// Flags returns the flags set in the filename of the message, in order.
+func (m *Message) Flags() ([]string, error) {
+	_, flags, err := m.info()
+	if err != nil {
+		return nil, err
+	}
 
-		if !strings.Contains(line, "Content-Transfer-Encoding") {
-			if !boundB || boundB && line != bound {
-				if write {
-					buf.WriteString(line + newline)
-				}
-			}
-		}
+	var set []string
+	for _, f := range sortFlags(flags) {
+		set = append(set, string(f))
+	}
+	return set, nil
+}
 
-		if strings.Contains(line, "Content-Type: text/plain") {
-			write = true
-		}
+// SetFlags replaces all the filename flags on the message with the given ones.
+func (m *Message) SetFlags(flags ...string) error {
+	name, old, err := m.info()
+	if err != nil {
+		return err
+	}
+
+	newflags := sortFlags(strings.Join(flags, ""))
+	if newflags == old {
+		return nil
 	}
-	return string(buf.Bytes())
+
+	newname := name + infotag + newflags
+	if err := os.Rename(m.filename, newname); err != nil {
+		return err
+	}
+	m.filename = newname
+	return nil
+}
+
+// Flag sets a filename flag on the message.
+func (m *Message) Flag(flag string) error {
+	_, flags, err := m.info()
+	if err != nil {
+		return err
+	}
+	return m.SetFlags(flags, flag)
+}
+
+// Unflag removes a filename flag from the message.
+func (m *Message) Unflag(flag string) error {
+	_, flags, err := m.info()
+	if err != nil {
+		return err
+	}
+	return m.SetFlags(strings.Replace(flags, flag, "", -1))
+}
+
+// IsFlagged checks if the filename of the message is flagged with the given flag.
+// A message whose filename carries no flags at all is never flagged.
+func (m *Message) IsFlagged(flag string) bool {
+	_, flags, err := m.info()
+	return err == nil && strings.Contains(flags, flag)
+}
+
+// Unread returns true if the message is unread.
+func (m *Message) Unread() bool {
+	return !m.IsFlagged(Seen)
 }
 
 // WriteMessage interactively prompts the user for an email to send.
 // rewrite args for variable/optional second reader.
-func WriteMessage(r io.Reader, con io.Reader) *Message {
+func WriteMessage(r io.Reader, con io.Reader) (*Message, error) {
 	cli := bufio.NewScanner(r)
 
 	fmt.Print("From: ")
@@ -225,16 +312,13 @@ func WriteMessage(r io.Reader, con io.Reader) *Message {
 	subject := cli.Text()
 	content := WriteContent(con)
 
-	msg := "Content-Type: text/plain; charset=UTF-8\r\n"
-	msg += fmt.Sprintf(
-		"To: %v\r\nFrom: %v\r\nSubject: %v\r\n\r\n%v",
-		to, from, subject, content)
+	b := NewBuilder()
+	b.SetHeader("To", to)
+	b.SetHeader("From", from)
+	b.SetHeader("Subject", subject)
+	b.SetText(content)
 
-	m, err := ReadMessage(strings.NewReader(msg))
-	if err != nil {
-		log.Fatal(err)
-	}
-	return m
+	return b.Message()
 }
 
 // WriteContent interactively prompts the user for email content.
@@ -256,8 +340,7 @@ func WriteContent(r io.Reader) string {
 }
 
 // Save writes a Message to a file.
+// To add a message to a mailbox, use Maildir.Deliver or Maildir.Store instead.
 func Save(file string, m string) error {
-	b := bytes.NewBufferString(m).Bytes()
-	ioutil.WriteFile(file, b, 0600)
-	return nil
+	return ioutil.WriteFile(file, []byte(m), 0600)
 }
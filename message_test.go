@@ -36,6 +36,19 @@ func Test_MessageString(t *testing.T) {
 	}
 }
 
+func Test_LazyContent(t *testing.T) {
+	m := scanStr(msgStr)
+	if m.Body != nil {
+		t.Fatal("scanned message holds its body before it is read")
+	}
+	if m.Content() != "Test Content\r\n" {
+		t.Fatalf("content read from file as %q", m.Content())
+	}
+	if m.Body != nil {
+		t.Fatal("reading the content of a scanned message kept its body")
+	}
+}
+
 func Test_EmptyMessage(t *testing.T) {
 	r := strings.NewReader("")
 	m, _ := gomua.ReadMessage(r)
@@ -52,7 +65,10 @@ func Test_WriteMessage(t *testing.T) {
 
 	r := strings.NewReader(wStr)
 	c := strings.NewReader(cStr)
-	mScan := gomua.WriteMessage(r, c)
+	mScan, err := gomua.WriteMessage(r, c)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if m.String() != mScan.String() {
 		fmt.Println([]byte(m.String()))
 		fmt.Println([]byte(mScan.String()))
@@ -82,4 +98,26 @@ func Test_MessageFlags(t *testing.T) {
 	if !strings.Contains(m.Filename(), "RS") {
 		t.Fatalf("flags are not being reordered.")
 	}
+	if err := m.Unflag(gomua.Seen); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Unread() || !m.IsFlagged(gomua.Replied) {
+		t.Fatalf("Unflagging Seen did not leave only Replied: %s", m.Filename())
+	}
+	if err := m.SetFlags(gomua.Seen, gomua.Flagged, gomua.Seen); err != nil {
+		t.Fatal(err)
+	}
+	flags, err := m.Flags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(flags, "") != "FS" {
+		t.Fatalf("SetFlags left flags %v, expected [F S]", flags)
+	}
+	m.SetFlags(gomua.Replied, gomua.Seen)
+
+	err = (&gomua.Message{}).Flag(gomua.Seen)
+	if _, ok := err.(*gomua.FilenameError); !ok {
+		t.Fatalf("flagging a message without an info section returned %v, expected a FilenameError", err)
+	}
 }
@@ -0,0 +1,207 @@
+package gomua
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+)
+
+// defaultMediaType is assumed for parts that carry no Content-Type header (RFC 2045, section 5.2).
+const defaultMediaType = "text/plain"
+
+// A Part is one node of a Message's MIME tree.
+// Multipart parts hold their children in Parts, all other parts hold their body in Body,
+// already decoded from the Content-Transfer-Encoding given in the part's own Header.
+type Part struct {
+	Header            textproto.MIMEHeader
+	MediaType         string
+	Params            map[string]string
+	Disposition       string
+	DispositionParams map[string]string
+	Body              []byte
+	Parts             []*Part
+}
+
+// IsMultipart returns true if the Part is a multipart container.
+func (p *Part) IsMultipart() bool {
+	return strings.HasPrefix(p.MediaType, "multipart/")
+}
+
+// IsAttachment returns true if the Part was marked as an attachment by the sender.
+func (p *Part) IsAttachment() bool {
+	return p.Disposition == "attachment"
+}
+
+// Walk calls fn for the Part and every Part below it, depth first.
+func (p *Part) Walk(fn func(*Part)) {
+	fn(p)
+	for _, c := range p.Parts {
+		c.Walk(fn)
+	}
+}
+
+// Text returns the Body of the Part converted to UTF-8 from the charset named in its parameters.
+// Bodies in charsets without a registered CharsetDecoder are returned as is.
+func (p *Part) Text() string {
+	return toUTF8(p.Params["charset"], p.Body)
+}
+
+// TextParts returns the leaf parts that should be displayed as the text of a message.
+// Within a multipart/alternative the text/plain version is preferred over any other,
+// while the displayable parts of other containers are all returned in order.
+func (p *Part) TextParts() []*Part {
+	switch {
+	case p.MediaType == "multipart/alternative":
+		var best []*Part
+		for _, c := range p.Parts {
+			tps := c.TextParts()
+			if len(tps) == 0 {
+				continue
+			}
+			if allPlain(tps) {
+				return tps
+			}
+			if best == nil {
+				best = tps
+			}
+		}
+		return best
+	case p.MediaType == "multipart/related", p.MediaType == "multipart/signed":
+		// only the root (first) part is the content, the rest support it
+		if len(p.Parts) == 0 {
+			return nil
+		}
+		return p.Parts[0].TextParts()
+	case p.IsMultipart():
+		var tps []*Part
+		for _, c := range p.Parts {
+			tps = append(tps, c.TextParts()...)
+		}
+		return tps
+	case strings.HasPrefix(p.MediaType, "text/") && !p.IsAttachment():
+		return []*Part{p}
+	}
+	return nil
+}
+
+// allPlain checks that every part is text/plain.
+func allPlain(ps []*Part) bool {
+	for _, p := range ps {
+		if p.MediaType != "text/plain" {
+			return false
+		}
+	}
+	return true
+}
+
+// MIME parses the Message content into a tree of Parts, the root of which describes the Message itself.
+// A malformed multipart body returns the Parts that could be read along with the error.
+func (m *Message) MIME() (*Part, error) {
+	return parsePart(textproto.MIMEHeader(m.Header), strings.NewReader(m.Content()))
+}
+
+// parsePart reads a single part, and all its children if it is a multipart container.
+func parsePart(header textproto.MIMEHeader, body io.Reader) (*Part, error) {
+	p := &Part{Header: header}
+
+	p.MediaType, p.Params = parseMediaHeader(header.Get("Content-Type"))
+	if p.MediaType == "" {
+		p.MediaType = defaultMediaType
+	}
+	p.Disposition, p.DispositionParams = parseMediaHeader(header.Get("Content-Disposition"))
+
+	boundary := p.Params["boundary"]
+	if !p.IsMultipart() || boundary == "" {
+		b, err := ioutil.ReadAll(body)
+		p.Body = decodeTransfer(header.Get("Content-Transfer-Encoding"), b)
+		return p, err
+	}
+
+	mr := multipart.NewReader(body, boundary)
+	for {
+		raw, err := mr.NextRawPart()
+		if err == io.EOF {
+			return p, nil
+		}
+		if err != nil {
+			return p, err
+		}
+
+		child, err := parsePart(raw.Header, raw)
+		p.Parts = append(p.Parts, child)
+		if err != nil {
+			return p, err
+		}
+	}
+}
+
+// decodeTransfer undoes the quoted-printable or base64 Content-Transfer-Encoding of a body.
+// Identity encodings (7bit, 8bit, binary), unknown encodings, and bodies that fail to decode are returned unchanged.
+func decodeTransfer(encoding string, b []byte) []byte {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(bytes.NewReader(b))
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, bytes.NewReader(bytes.TrimSpace(b)))
+	default:
+		return b
+	}
+
+	decoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		return b
+	}
+	return decoded
+}
+
+// parseMediaHeader parses a Content-Type or Content-Disposition value, keeping
+// whatever could be read from a malformed one. Media types are always lower case.
+func parseMediaHeader(v string) (string, map[string]string) {
+	if v == "" {
+		return "", map[string]string{}
+	}
+	mt, params, err := mime.ParseMediaType(v)
+	if err != nil && mt == "" {
+		mt = strings.ToLower(strings.TrimSpace(strings.Split(v, ";")[0]))
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	return mt, params
+}
+
+// SanitizeContent returns only the displayable text of the email, as chosen by Part.TextParts,
+// with line endings normalized to CRLF.
+// If no MIME parts can be read at all, the raw content is returned instead.
+func (m *Message) SanitizeContent() string {
+	root, _ := m.MIME()
+	if root == nil || root.IsMultipart() && len(root.Parts) == 0 {
+		return normalizeNewlines(m.Content())
+	}
+
+	var texts []string
+	for _, p := range root.TextParts() {
+		texts = append(texts, normalizeNewlines(p.Text()))
+	}
+	return strings.Join(texts, newline)
+}
+
+// normalizeNewlines rewrites every line ending as CRLF.
+func normalizeNewlines(s string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	buf := new(bytes.Buffer)
+	for _, l := range lines {
+		buf.WriteString(strings.TrimSuffix(l, "\r") + newline)
+	}
+	return buf.String()
+}
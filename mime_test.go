@@ -0,0 +1,122 @@
+package gomua_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/frenata/gomua"
+)
+
+var mixedStr = "From: test1@testing.com\r\n" +
+	"Subject: nested\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer=_b\"; charset=us-ascii\r\n" +
+	"\r\n" +
+	"This is a multi-part message in MIME format.\r\n" +
+	"--outer=_b\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=us-ascii\r\n" +
+	"\r\n" +
+	"Plain body\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=us-ascii\r\n" +
+	"\r\n" +
+	"<p>HTML body</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer=_b\r\n" +
+	"Content-Type: application/pdf; name=\"report.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"report.pdf\"\r\n" +
+	"\r\n" +
+	"%PDF-1.4\r\n" +
+	"--outer=_b--\r\n"
+
+func Test_MIMETree(t *testing.T) {
+	m, err := gomua.ReadMessage(strings.NewReader(mixedStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := m.MIME()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.MediaType != "multipart/mixed" || root.Params["charset"] != "us-ascii" {
+		t.Fatalf("root part parsed as %s %v", root.MediaType, root.Params)
+	}
+	if len(root.Parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(root.Parts))
+	}
+
+	alt := root.Parts[0]
+	if alt.MediaType != "multipart/alternative" || len(alt.Parts) != 2 {
+		t.Fatalf("nested alternative parsed as %s with %d parts", alt.MediaType, len(alt.Parts))
+	}
+
+	att := root.Parts[1]
+	if !att.IsAttachment() || att.DispositionParams["filename"] != "report.pdf" {
+		t.Fatalf("attachment parsed as %s %v", att.Disposition, att.DispositionParams)
+	}
+
+	text := m.SanitizeContent()
+	if text != "Plain body\r\n" {
+		t.Fatalf("expected only the plain alternative, got %q", text)
+	}
+}
+
+func Test_MIMEHTMLOnly(t *testing.T) {
+	msg := "Content-Type: multipart/alternative; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/html\r\n\r\n<p>only html</p>\r\n--b--\r\n"
+	m, err := gomua.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text := m.SanitizeContent(); text != "<p>only html</p>\r\n" {
+		t.Fatalf("expected html fallback, got %q", text)
+	}
+}
+
+func Test_MIMETransferEncoding(t *testing.T) {
+	msg := "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"It=E2=80=99s a soft=\r\n line break\r\n" +
+		"--b\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+		"U2Vjb25kIHBh\r\ncnQ=\r\n" +
+		"--b--\r\n"
+	m, err := gomua.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "It’s a soft line break\r\n\r\nSecond part\r\n"
+	if text := m.SanitizeContent(); text != want {
+		t.Fatalf("expected decoded parts %q, got %q", want, text)
+	}
+}
+
+func Test_Attachments(t *testing.T) {
+	m, err := gomua.ReadMessage(strings.NewReader(mixedStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atts, err := m.Attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atts) != 1 {
+		t.Fatalf("expected 1 attachment, got %d", len(atts))
+	}
+
+	a := atts[0]
+	if a.Name != "report.pdf" || a.MediaType != "application/pdf" || a.Size != int64(len("%PDF-1.4")) {
+		t.Fatalf("attachment listed as %s", a)
+	}
+	b, _ := ioutil.ReadAll(a.Reader())
+	if string(b) != "%PDF-1.4" {
+		t.Fatalf("attachment content read as %q", b)
+	}
+}
@@ -0,0 +1,406 @@
+package gomua
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/frenata/gomua/cache"
+)
+
+// A Query selects the Mail matching a search, as parsed by ParseQuery.
+//
+// A query is a list of terms, all of which must match. A term is a word or "quoted phrase" found
+// in the From, To, Cc or Subject headers or the text of a Message, or one of:
+//
+//	from:x to:x subject:x body:x   x found in the From, To or Cc, Subject header, or the text
+//	before:date after:date         sent before the given day, or on or after it: 2006-01-02, 2006-01 or 2006
+//	is:unread is:read is:flagged is:replied
+//	has:attachment
+//	tag:x                          tagged x in the Tags of the Query
+//
+// Terms are joined with OR, negated with NOT or a leading -, and grouped with parentheses.
+// AND may be written between terms, but is implied. Matching ignores case.
+type Query struct {
+	// Index, if set, narrows body searches to the Messages it holds that may contain the text,
+	// so that only those are read. It does not change which Messages match.
+	Index *cache.TextIndex
+	// Tags holds the tags searched by tag: terms. Without it, they match nothing.
+	Tags *TagDB
+
+	text    string
+	root    queryNode
+	hits    map[string]map[string]bool // Index search candidates by value, nil if the value has no indexed words
+	hitsIdx *cache.TextIndex           // the Index hits were read from
+}
+
+// A queryNode is one operator or term of a parsed Query.
+type queryNode interface{}
+
+type (
+	andNode []queryNode
+	orNode  []queryNode
+	notNode struct{ node queryNode }
+
+	// a term compares one field of a Message with a value
+	termNode struct {
+		field string // "" for a bare term
+		value string // lower case
+		date  time.Time
+	}
+)
+
+// queryFields are the fields a term can search.
+var queryFields = map[string]bool{
+	"from": true, "to": true, "subject": true, "body": true,
+	"before": true, "after": true, "is": true, "has": true, "tag": true,
+}
+
+// ParseQuery parses a query string into a Query.
+func ParseQuery(query string) (*Query, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("query: empty query")
+	}
+
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("query: unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Query{text: query, root: root}, nil
+}
+
+// String returns the query string the Query was parsed from.
+func (q *Query) String() string { return q.text }
+
+// Match checks if a Mail matches the Query. A MessageThread matches if any of its Messages does,
+// and Mail of any other type never matches.
+func (q *Query) Match(m Mail) bool {
+	switch m := m.(type) {
+	case *Message:
+		return q.eval(q.root, m)
+	case *MessageThread:
+		for _, msg := range m.Messages() {
+			if q.eval(q.root, msg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SearchesText checks if the Query has terms that search the text of Messages, and so can use an Index.
+func (q *Query) SearchesText() bool { return searchesText(q.root) }
+
+// searchesText checks if a node of a Query has a body: or bare term.
+func searchesText(n queryNode) bool {
+	switch n := n.(type) {
+	case andNode:
+		for _, c := range n {
+			if searchesText(c) {
+				return true
+			}
+		}
+	case orNode:
+		for _, c := range n {
+			if searchesText(c) {
+				return true
+			}
+		}
+	case notNode:
+		return searchesText(n.node)
+	case *termNode:
+		return n.field == "" || n.field == "body"
+	}
+	return false
+}
+
+// Filter returns the Mail in msgs that matches the Query, in order.
+func (q *Query) Filter(msgs []Mail) []Mail {
+	q.hits = nil // the Index may have changed since the last search
+	var matches []Mail
+	for _, m := range msgs {
+		if q.Match(m) {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// Search parses a query string and returns the Mail in msgs that matches it.
+func Search(msgs []Mail, query string) ([]Mail, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Filter(msgs), nil
+}
+
+// eval checks if a Message matches a node of the Query.
+func (q *Query) eval(n queryNode, m *Message) bool {
+	switch n := n.(type) {
+	case andNode:
+		for _, c := range n {
+			if !q.eval(c, m) {
+				return false
+			}
+		}
+		return true
+	case orNode:
+		for _, c := range n {
+			if q.eval(c, m) {
+				return true
+			}
+		}
+		return false
+	case notNode:
+		return !q.eval(n.node, m)
+	case *termNode:
+		return q.matchTerm(n, m)
+	}
+	return false
+}
+
+// matchTerm checks if a Message matches a single term.
+func (q *Query) matchTerm(t *termNode, m *Message) bool {
+	has := func(s string) bool { return strings.Contains(strings.ToLower(s), t.value) }
+
+	switch t.field {
+	case "":
+		return has(m.From()) || has(m.To()) || has(m.DecodedHeader("Cc")) || has(m.Subject()) ||
+			q.matchBody(t.value, m)
+	case "from":
+		return has(m.From())
+	case "to":
+		return has(m.To()) || has(m.DecodedHeader("Cc"))
+	case "subject":
+		return has(m.Subject())
+	case "body":
+		return q.matchBody(t.value, m)
+	case "before":
+		return m.Date().Before(t.date)
+	case "after":
+		return !m.Date().Before(t.date)
+	case "is":
+		switch t.value {
+		case "unread":
+			return m.Unread()
+		case "read":
+			return !m.Unread()
+		case "flagged":
+			return m.IsFlagged(Flagged)
+		case "replied":
+			return m.IsFlagged(Replied)
+		}
+	case "has":
+		atts, _ := m.Attachments()
+		return len(atts) > 0
+	case "tag":
+		return q.Tags != nil && q.Tags.HasTag(m, t.value)
+	}
+	return false
+}
+
+// matchBody checks if the text of a Message contains a lower case value. If the Index holds the Message,
+// only the Messages it finds as candidates are read.
+func (q *Query) matchBody(value string, m *Message) bool {
+	if q.Index != nil && m.filename != "" && q.Index.Indexed(m.filename) {
+		if q.hitsIdx != q.Index {
+			q.hits, q.hitsIdx = nil, q.Index
+		}
+		keys, ok := q.hits[value]
+		if !ok {
+			keys, _ = q.Index.Search(value)
+			if q.hits == nil {
+				q.hits = make(map[string]map[string]bool)
+			}
+			q.hits[value] = keys
+		}
+		if keys != nil && !keys[cache.Key(m.filename)] {
+			return false
+		}
+	}
+	return strings.Contains(strings.ToLower(m.SanitizeContent()), value)
+}
+
+// A queryToken is a word, quoted phrase or parenthesis of a query string.
+type queryToken struct {
+	text   string
+	quoted bool // any part was quoted, so it is never an operator
+}
+
+// tokenizeQuery splits a query string into tokens. A quoted phrase may follow a field, as in subject:"a b".
+func tokenizeQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		switch r := rs[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{text: string(r)})
+			i++
+		case r == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]):
+			// a leading - negates what follows, whether a term, phrase or group
+			tokens = append(tokens, queryToken{text: "-"})
+			i++
+		default:
+			var tok queryToken
+			var b strings.Builder
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' {
+				if rs[i] != '"' {
+					b.WriteRune(rs[i])
+					i++
+					continue
+				}
+				tok.quoted = true
+				for i++; i < len(rs) && rs[i] != '"'; i++ {
+					if rs[i] == '\\' && i+1 < len(rs) {
+						i++
+					}
+					b.WriteRune(rs[i])
+				}
+				if i == len(rs) {
+					return nil, errors.New("query: unterminated quote")
+				}
+				i++
+			}
+			tok.text = b.String()
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens, nil
+}
+
+// A queryParser builds the nodes of a Query from its tokens, by recursive descent:
+//
+//	or   = and { "OR" and }
+//	and  = not { ["AND"] not }
+//	not  = ("NOT" | "-") not | "(" or ")" | term
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// peek returns the next token if it is the given operator or parenthesis.
+func (p *queryParser) peek(op string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == op
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	var or orNode
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, n)
+		if !p.peek("OR") {
+			break
+		}
+		p.pos++
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var and andNode
+	for {
+		if p.peek("AND") && len(and) > 0 {
+			p.pos++
+		}
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, n)
+		if p.pos == len(p.tokens) || p.peek("OR") || p.peek(")") {
+			break
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.pos == len(p.tokens) {
+		return nil, errors.New("query: missing term at end")
+	}
+	tok := p.tokens[p.pos]
+	switch {
+	case p.peek("NOT"), p.peek("-"):
+		p.pos++
+		n, err := p.parseNot()
+		return notNode{n}, err
+	case p.peek("("):
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, errors.New("query: missing )")
+		}
+		p.pos++
+		return n, nil
+	case p.peek(")"), p.peek("OR"), p.peek("AND"):
+		return nil, fmt.Errorf("query: unexpected %q", tok.text)
+	}
+	p.pos++
+	return parseTerm(tok.text)
+}
+
+// parseTerm parses a single term, with or without a field.
+func parseTerm(s string) (*termNode, error) {
+	t := &termNode{value: strings.ToLower(s)}
+	if i := strings.Index(s, ":"); i > 0 && queryFields[strings.ToLower(s[:i])] {
+		t.field, t.value = strings.ToLower(s[:i]), strings.ToLower(s[i+1:])
+	}
+	if t.value == "" {
+		return nil, fmt.Errorf("query: %s: missing value", s)
+	}
+
+	switch t.field {
+	case "before", "after":
+		d, err := parseQueryDate(t.value)
+		if err != nil {
+			return nil, fmt.Errorf("query: %s: %v", s, err)
+		}
+		t.date = d
+	case "is":
+		switch t.value {
+		case "unread", "read", "flagged", "replied":
+		default:
+			return nil, fmt.Errorf("query: %s: expected is:unread, is:read, is:flagged or is:replied", s)
+		}
+	case "has":
+		if t.value != "attachment" {
+			return nil, fmt.Errorf("query: %s: expected has:attachment", s)
+		}
+	}
+	return t, nil
+}
+
+// parseQueryDate reads the date of a before: or after: term, as the start of that day in local time.
+func parseQueryDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "2006-01", "2006"} {
+		if d, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, errors.New("expected a date as 2006-01-02, 2006-01 or 2006")
+}
@@ -0,0 +1,198 @@
+package gomua_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frenata/gomua"
+	"github.com/frenata/gomua/cache"
+)
+
+func Test_Search(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(root)
+	if err := md.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	store := func(flags string, headers string, body string) gomua.Mail {
+		path, err := md.Store(strings.NewReader(headers+"\r\n\r\n"+body), flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gomua.Scan(path)[0]
+	}
+	plan := store("S", "From: Ann <ann@x>\r\nTo: bob@x\r\nDate: Mon, 2 Feb 2015 10:00:00 +0000\r\nSubject: The plan",
+		"Meet at noon.\r\n")
+	reply := store("FS", "From: Bob <bob@x>\r\nTo: ann@x\r\nCc: cid@x\r\nDate: Tue, 3 Feb 2015 10:00:00 +0000\r\nSubject: Re: The plan",
+		"Noon is fine, see the \"agenda\" attached.\r\n")
+	news := store("", "From: List <list@y>\r\nTo: ann@x\r\nDate: Sun, 1 Mar 2015 10:00:00 +0000\r\nSubject: News\r\n"+
+		"Content-Type: multipart/mixed; boundary=b",
+		"--b\r\nContent-Type: text/plain\r\n\r\nnews of the week\r\n--b\r\n"+
+			"Content-Type: application/pdf\r\nContent-Disposition: attachment; filename=a.pdf\r\n\r\n%PDF\r\n--b--\r\n")
+	msgs := []gomua.Mail{plan, reply, news}
+
+	tests := []struct {
+		query string
+		want  []gomua.Mail
+	}{
+		{"plan", []gomua.Mail{plan, reply}},
+		{"from:ann", []gomua.Mail{plan}},
+		{"to:cid", []gomua.Mail{reply}},
+		{"subject:\"the plan\"", []gomua.Mail{plan, reply}},
+		{"body:noon", []gomua.Mail{plan, reply}},
+		{"\"the \\\"agenda\\\"\"", []gomua.Mail{reply}},
+		{"before:2015-02-03", []gomua.Mail{plan}},
+		{"after:2015-02", []gomua.Mail{plan, reply, news}},
+		{"after:2015-02-03 before:2015-03", []gomua.Mail{reply}},
+		{"is:unread", []gomua.Mail{news}},
+		{"is:flagged", []gomua.Mail{reply}},
+		{"has:attachment", []gomua.Mail{news}},
+		{"from:ann OR from:list", []gomua.Mail{plan, news}},
+		{"plan AND NOT is:flagged", []gomua.Mail{plan}},
+		{"-plan", []gomua.Mail{news}},
+		{"-(from:ann OR from:bob) week", []gomua.Mail{news}},
+		{"\"OR\"", nil},
+	}
+	for _, tt := range tests {
+		got, err := gomua.Search(msgs, tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d matches, expected %d", tt.query, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: match %d is %s", tt.query, i, got[i].Summary())
+			}
+		}
+	}
+
+	thread := gomua.Thread(msgs)
+	q, _ := gomua.ParseQuery("is:flagged")
+	matched := 0
+	for _, th := range thread {
+		if q.Match(th) {
+			matched++
+		}
+	}
+	if matched != 1 {
+		t.Errorf("%d threads match is:flagged, expected 1", matched)
+	}
+
+	for query, want := range map[string]bool{"plan": true, "is:unread -body:x": true, "from:ann is:flagged": false} {
+		if q, _ := gomua.ParseQuery(query); q.SearchesText() != want {
+			t.Errorf("%s: SearchesText is %v", query, !want)
+		}
+	}
+
+	for _, bad := range []string{"", "(plan", "plan)", "\"plan", "is:red", "before:soon", "from:", "plan OR", "AND plan"} {
+		if _, err := gomua.ParseQuery(bad); err == nil {
+			t.Errorf("%q parsed without error", bad)
+		}
+	}
+}
+
+func Test_SearchTextIndex(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(root)
+	if err := md.Create(); err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"The meetings are on Monday.", "No meeting on Tuesday.", "Nothing planned."} {
+		if _, err := md.Store(strings.NewReader("Subject: week\r\n\r\n"+body), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := gomua.Scan(root)
+
+	ix, err := cache.OpenTextIndex(filepath.Join(root, "text"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := gomua.IndexText(ix, msgs); n != 3 || err != nil {
+		t.Fatalf("indexed %d messages, %v", n, err)
+	}
+	if err := msgs[0].(*gomua.Message).Flag(gomua.Seen); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := gomua.IndexText(ix, msgs); n != 0 {
+		t.Fatalf("indexed %d unchanged messages again", n)
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"body:meeting", 2},
+		{"body:meet", 2},
+		{"body:meetings", 1},
+		{"body:\"monday meetings\"", 0},
+		{"body:\"meetings are\"", 1},
+		{"body:ann", 1},
+		{"body:week", 0},
+		{"meeting -tuesday", 1},
+	}
+	for _, tt := range tests {
+		q, err := gomua.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(q.Filter(msgs)); n != tt.want {
+			t.Errorf("%s: %d matches reading messages, expected %d", tt.query, n, tt.want)
+		}
+		q.Index = ix
+		if n := len(q.Filter(msgs)); n != tt.want {
+			t.Errorf("%s: %d matches from the index, expected %d", tt.query, n, tt.want)
+		}
+	}
+}
+
+func Test_SearchTwoTextIndexes(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	q, err := gomua.ParseQuery("body:meeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		md := gomua.Maildir(filepath.Join(root, name))
+		if err := md.Create(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := md.Store(strings.NewReader("Subject: week\r\n\r\nA meeting on Monday."), ""); err != nil {
+			t.Fatal(err)
+		}
+		msgs := gomua.Scan(string(md))
+		ix, err := cache.OpenTextIndex(filepath.Join(string(md), "text"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := gomua.IndexText(ix, msgs); n != 1 || err != nil {
+			t.Fatalf("%s: indexed %d messages, %v", name, n, err)
+		}
+
+		q.Index = ix
+		if n := len(q.Filter(msgs)); n != 1 {
+			t.Errorf("%s: %d matches from the index, expected 1", name, n)
+		}
+	}
+}
@@ -1,75 +1,203 @@
 package gomua
 
 import (
-	"bytes"
-	"fmt"
-	"io"
-	"io/ioutil"
+	"bufio"
+	"context"
+	"net/mail"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/frenata/gomua/cache"
 )
 
-// A mailDir defines a mail directory and a list of messages in that directory.
+// A ScanError records a path that could not be read as a Message during a scan.
+type ScanError struct {
+	Path string
+	Err  error
+}
+
+func (e *ScanError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// A ScanResult holds the Messages found by a scan, and an error for every path that could not be read.
+type ScanResult struct {
+	Messages []Mail
+	Errors   []*ScanError
+}
+
+// A Scanner reads the files under a path concurrently, with a bounded pool of workers.
+type Scanner struct {
+	// Workers is the most files read at once. Zero or less means one per CPU.
+	Workers int
+	// Index, if set, supplies the headers of files that have not changed since they were indexed,
+	// and records those of the files that have to be read.
+	Index *cache.Index
+}
+
+// A mailDir defines a mail directory and the files found in it, in walk order.
 type mailDir struct {
-	dir  string
-	msgs []Mail
+	dir     string
+	entries []*scanEntry
+}
+
+// A scanEntry is one path found while walking a mailDir, and the outcome of reading it.
+type scanEntry struct {
+	path string
+	info os.FileInfo
+	msg  *Message
+	err  error
 }
 
 // newMailDir returns a new mailDir ready to read a given directory.
 func newMailDir(dir string) *mailDir {
-	var msgs []Mail
-	return &mailDir{dir: dir, msgs: msgs}
+	return &mailDir{dir: dir}
 }
 
-// processFile reads from a given file and creates a new mail Message.
-func (md *mailDir) processFile(filename string, in io.Reader) error {
-	if in == nil {
-		b, err := ioutil.ReadFile(filename)
-		if err != nil {
-			return err
-		}
-		in = bytes.NewReader(b)
+// readFile reads the headers of the file with the given name and creates a new mail Message.
+// The body is left on disk, to be read when the Message content is asked for.
+func readFile(filename string) (*Message, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	msg, err := ReadMessage(in)
-	msg.filename, _ = filepath.Abs(filename)
+	msg, err := ReadMessage(bufio.NewReader(f))
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	md.msgs = append(md.msgs, msg)
-	return nil
+	msg.Body = nil
+	if msg.filename, err = filepath.Abs(filename); err != nil {
+		return nil, err
+	}
+	return msg, nil
 }
 
-// visitFile processes the file if there is no error.
+// visitFile records each regular file to be read, and any error met while walking.
 func (md *mailDir) visitFile(path string, f os.FileInfo, err error) error {
 	if err != nil {
-		// This was printing errors when reaading a directory, nerfing the msg for now.
-		fmt.Printf("%s\n", err)
+		md.entries = append(md.entries, &scanEntry{path: path, err: err})
+		return nil
+	}
+	if !f.IsDir() {
+		md.entries = append(md.entries, &scanEntry{path: path, info: f})
 	}
-	err = md.processFile(path, nil)
-
 	return nil
 }
 
-// walkDir walks the directory structure and processes each file it finds.
+// walkDir walks the directory structure and records each file it finds.
 func (md *mailDir) walkDir(path string) {
 	filepath.Walk(path, md.visitFile)
 }
 
-// Scan checks all files for the given path and returns a slice of Messages.
-func Scan(path string) []Mail {
+// process reads every recorded file with the given number of workers, stopping early if ctx is done.
+func (md *mailDir) process(ctx context.Context, workers int, read func(*scanEntry)) error {
+	jobs := make(chan *scanEntry)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				read(e)
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for _, e := range md.entries {
+		if e.err != nil {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case jobs <- e:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return err
+}
+
+// result collects the outcome of every file read, in walk order.
+func (md *mailDir) result() *ScanResult {
+	res := new(ScanResult)
+	for _, e := range md.entries {
+		switch {
+		case e.err != nil:
+			res.Errors = append(res.Errors, &ScanError{Path: e.path, Err: e.err})
+		case e.msg != nil:
+			res.Messages = append(res.Messages, e.msg)
+		}
+	}
+	return res
+}
+
+// Scan checks all files for the given path, and returns the Messages found along with
+// an error for each file that could not be read. Both are in the order of a sequential walk
+// of the path, however many workers read them.
+// If ctx is done before every file is read, the files read so far are returned with ctx's error.
+func (s *Scanner) Scan(ctx context.Context, path string) (*ScanResult, error) {
 	md := newMailDir(path)
 
 	switch dir, err := os.Stat(md.dir); {
 	case err != nil:
-		fmt.Printf("%s", err)
+		md.entries = append(md.entries, &scanEntry{path: md.dir, err: err})
 	case dir.IsDir():
 		md.walkDir(md.dir)
 	default:
-		if err := md.processFile(md.dir, nil); err != nil {
-			fmt.Printf("%s", err)
+		md.entries = append(md.entries, &scanEntry{path: md.dir, info: dir})
+	}
+
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	err := md.process(ctx, workers, s.read)
+	return md.result(), err
+}
+
+// read creates the Message of a scanned file, from the Index if it holds an up to date Entry for it.
+func (s *Scanner) read(e *scanEntry) {
+	if s.Index == nil {
+		e.msg, e.err = readFile(e.path)
+		return
+	}
+
+	if ie, ok := s.Index.Lookup(e.path, e.info); ok {
+		abs, err := filepath.Abs(e.path)
+		if err != nil {
+			e.err = err
+			return
 		}
+		e.msg = &Message{Message: &mail.Message{Header: mail.Header(ie.Header)}, filename: abs}
+		return
+	}
+
+	e.msg, e.err = readFile(e.path)
+	if e.err == nil {
+		s.Index.Add(e.path, e.info, e.msg.Header)
 	}
-	return md.msgs
+}
+
+// ScanPath checks all files for the given path, and returns the Messages found along with
+// an error for each file that could not be read.
+func ScanPath(path string) *ScanResult {
+	res, _ := new(Scanner).Scan(context.Background(), path)
+	return res
+}
+
+// Scan checks all files for the given path and returns a slice of Messages.
+// Files that cannot be read are skipped; use ScanPath to find out which.
+func Scan(path string) []Mail {
+	return ScanPath(path).Messages
 }
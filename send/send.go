@@ -5,8 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/smtp"
+	"net/textproto"
 	"strconv"
 	"strings"
 	"time"
@@ -90,13 +90,44 @@ func connectSMTP(s *SMTPServer) (*smtp.Client, error) {
 	return c, nil
 }
 
+// An Error records a step of an SMTP transaction that failed, with the reply code if the server refused it.
+type Error struct {
+	Op   string
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return "SMTP " + e.Op + ": " + e.Err.Error()
+}
+
+// A RecipientError reports a recipient address that the SMTP server rejected, with the server's reply.
+type RecipientError struct {
+	Address string
+	Code    int
+	Msg     string
+}
+
+func (e *RecipientError) Error() string {
+	return fmt.Sprintf("SMTP: recipient %s rejected: %d %s", e.Address, e.Code, e.Msg)
+}
+
+// newError wraps err from the SMTP step op, keeping the server reply code if there is one.
+func newError(op string, err error) error {
+	e := &Error{Op: op, Err: err}
+	if tp, ok := err.(*textproto.Error); ok {
+		e.Code = tp.Code
+	}
+	return e
+}
+
 // SendSMTP takes a SMTP server and a message, connects to the server, sends the message, and quits the connection to the server.
 func sendSMTP(server *SMTPServer, msg *gomua.Message) error {
 	// connect to SMTP server
 	var c *smtp.Client
 	c, err := connectSMTP(server)
 	if err != nil {
-		log.Fatal(err)
+		return newError("connect", err)
 	}
 	defer c.Quit()
 
@@ -104,12 +135,12 @@ func sendSMTP(server *SMTPServer, msg *gomua.Message) error {
 	if len(from) != 0 {
 		for _, t := range from {
 			if err := c.Mail(t.Address); err != nil {
-				log.Fatal(err)
+				return newError("sender "+t.Address, err)
 			}
 		}
 	} else {
 		if err := c.Mail(server.username); err != nil {
-			log.Fatal(err)
+			return newError("sender "+server.username, err)
 		}
 
 	}
@@ -117,14 +148,17 @@ func sendSMTP(server *SMTPServer, msg *gomua.Message) error {
 	to, _ := msg.Header.AddressList("To")
 	for _, t := range to {
 		if err := c.Rcpt(t.Address); err != nil {
-			log.Fatal(err)
+			if tp, ok := err.(*textproto.Error); ok {
+				return &RecipientError{Address: t.Address, Code: tp.Code, Msg: tp.Msg}
+			}
+			return newError("recipient "+t.Address, err)
 		}
 	}
 
 	// Send email body
 	wc, err := c.Data()
 	if err != nil {
-		log.Fatal(err)
+		return newError("data", err)
 	}
 
 	fmt.Fprintf(wc, "Date: %v\r\n", time.Now().Local().Format(time.RFC822))
@@ -144,28 +178,29 @@ func sendSMTP(server *SMTPServer, msg *gomua.Message) error {
 
 	_, err = fmt.Fprintf(wc, "\n%s\n", msg.Content())
 	if err != nil {
-		log.Fatal(err)
+		return newError("data", err)
 	}
 	err = wc.Close()
 	if err != nil {
-		log.Fatal(err)
+		return newError("data", err)
 	}
 
 	return nil
 }
 
 // Send opens a new SMTP server connection from the config file and sends a message.
-func Send(filename string, msg *gomua.Message) {
+func Send(filename string, msg *gomua.Message) error {
 	// Look for a SMTPServer configuration file in ~/.gomua/send.cfg
 	srv, err := NewSMTPServer(filename)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
 	fmt.Println("\nSending...")
 
-	if sendSMTP(srv, msg) == nil {
-		fmt.Println("Message Sent")
+	if err := sendSMTP(srv, msg); err != nil {
+		return err
 	}
+	fmt.Println("Message Sent")
+	return nil
 }
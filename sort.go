@@ -0,0 +1,156 @@
+package gomua
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A SortKey is an order that a list of Mail can be sorted in.
+type SortKey int
+
+// The orders of a list of Mail. A MessageThread is dated by its newest Message, and otherwise sorts
+// by its first Message, except by size, where its Messages are added up.
+const (
+	// ByDate sorts oldest first.
+	ByDate SortKey = iota
+	// ByFrom sorts alphabetically by sender.
+	ByFrom
+	// BySubject sorts alphabetically by subject, ignoring reply and forward prefixes.
+	BySubject
+	// BySize sorts smallest first.
+	BySize
+	// ByUnread sorts unread Mail first.
+	ByUnread
+	// ByThread keeps the Messages of each thread together, in date order, with the threads
+	// ordered by their newest Message, least recently active first.
+	ByThread
+)
+
+var sortKeyNames = []string{"date", "from", "subject", "size", "unread", "thread"}
+
+func (k SortKey) String() string {
+	if k < 0 || int(k) >= len(sortKeyNames) {
+		return fmt.Sprintf("SortKey(%d)", int(k))
+	}
+	return sortKeyNames[k]
+}
+
+// ParseSortKey returns the SortKey with the given name, as returned by its String method.
+func ParseSortKey(name string) (SortKey, error) {
+	for i, n := range sortKeyNames {
+		if strings.EqualFold(name, n) {
+			return SortKey(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sort order %q, expected one of %s", name, strings.Join(sortKeyNames, ", "))
+}
+
+// sortItem holds a Mail along with the values it is sorted by.
+type sortItem struct {
+	mail    Mail
+	unknown bool      // neither a Message nor a MessageThread
+	date    time.Time // the thread's newest for ByThread
+	own     time.Time // the Mail's own date, to order each thread for ByThread
+	text    string
+	n       int64
+}
+
+// Sort orders msgs by key, or the reverse if reverse is set. Mail that compares equal keeps its order.
+// Mail other than Messages and MessageThreads sorts last.
+func Sort(msgs []Mail, key SortKey, reverse bool) {
+	var latest map[*Message]time.Time
+	if key == ByThread {
+		latest = make(map[*Message]time.Time)
+		for _, t := range Thread(msgs) {
+			d := t.Latest()
+			for _, msg := range t.Messages() {
+				latest[msg] = d
+			}
+		}
+	}
+
+	items := make([]sortItem, len(msgs))
+	for i, m := range msgs {
+		items[i] = newSortItem(m, key, latest)
+	}
+
+	less := func(a, b *sortItem) bool {
+		switch key {
+		case ByDate:
+			return a.date.Before(b.date)
+		case ByFrom, BySubject:
+			return a.text < b.text
+		case ByThread:
+			if !a.date.Equal(b.date) {
+				return a.date.Before(b.date)
+			}
+			return a.own.Before(b.own)
+		default:
+			return a.n < b.n
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := &items[i], &items[j]
+		if a.unknown != b.unknown {
+			return b.unknown
+		}
+		if reverse {
+			a, b = b, a
+		}
+		return less(a, b)
+	})
+
+	for i := range items {
+		msgs[i] = items[i].mail
+	}
+}
+
+// newSortItem reads the values m is sorted by.
+func newSortItem(m Mail, key SortKey, latest map[*Message]time.Time) sortItem {
+	var msg *Message
+	var msgs []*Message
+	switch m := m.(type) {
+	case *Message:
+		msg, msgs = m, []*Message{m}
+	case *MessageThread:
+		msg, msgs = m.first(), m.Messages()
+	}
+	if msg == nil {
+		return sortItem{mail: m, unknown: true}
+	}
+
+	item := sortItem{mail: m}
+	switch key {
+	case ByDate:
+		if t, ok := m.(*MessageThread); ok {
+			item.date = t.Latest()
+		} else {
+			item.date = msg.Date()
+		}
+	case ByFrom:
+		item.text = strings.ToLower(msg.From())
+	case BySubject:
+		item.text, _ = baseSubject(msg.Subject())
+	case BySize:
+		for _, msg := range msgs {
+			item.n += msg.Size()
+		}
+	case ByUnread:
+		item.n = 1
+		for _, msg := range msgs {
+			if msg.Unread() {
+				item.n = 0
+			}
+		}
+	case ByThread:
+		if t, ok := m.(*MessageThread); ok {
+			item.date = t.Latest()
+		} else {
+			item.date = latest[msg]
+		}
+		item.own = msg.Date()
+	}
+	return item
+}
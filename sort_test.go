@@ -0,0 +1,105 @@
+package gomua_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frenata/gomua"
+)
+
+func Test_MessageDate(t *testing.T) {
+	tests := []struct {
+		headers string
+		want    string
+	}{
+		{"Date: Wed, 21 Jan 2015 02:00:03 -0500", "2015-01-21T02:00:03-05:00"},
+		{"Date: Wed 21 Jan 2015 02:00:03 GMT+0100", "2015-01-21T02:00:03+01:00"},
+		{"Date: 21 Jan 2015 02:00:03 -0500 (EST)", "2015-01-21T02:00:03-05:00"},
+		{"Date: Wed Jan 21 02:00:03 2015", "2015-01-21T02:00:03Z"},
+		{"Date: 2015-01-21 02:00:03 -0500", "2015-01-21T02:00:03-05:00"},
+		{"Date: garbage\r\nReceived: from mx by mail; Thu, 22 Jan 2015 10:00:00 +0000\r\nReceived: from a by mx; Wed, 21 Jan 2015 10:00:00 +0000", "2015-01-22T10:00:00Z"},
+	}
+	for _, tt := range tests {
+		m, err := gomua.ReadMessage(strings.NewReader(tt.headers + "\r\n\r\nbody\r\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Date().Format(time.RFC3339); got != tt.want {
+			t.Errorf("%q: dated %s, expected %s", tt.headers, got, tt.want)
+		}
+	}
+
+	m, _ := gomua.ReadMessage(strings.NewReader("Subject: undated\r\n\r\nbody\r\n"))
+	if !m.Date().IsZero() {
+		t.Errorf("message with no date dated %v", m.Date())
+	}
+}
+
+// sortMessage stores a message with the given flags in md, and returns it as scanned from its file.
+func sortMessage(t *testing.T, md gomua.Maildir, flags, id, refs, date, from, subject, body string) *gomua.Message {
+	path, err := md.Store(strings.NewReader("Message-Id: "+id+"\r\nReferences: "+refs+
+		"\r\nDate: "+date+"\r\nFrom: "+from+"\r\nSubject: "+subject+"\r\n\r\n"+body), flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := gomua.Scan(path)
+	if len(msgs) != 1 {
+		t.Fatalf("%s scanned as %d messages", path, len(msgs))
+	}
+	return msgs[0].(*gomua.Message)
+}
+
+func Test_Sort(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(root)
+	if err := md.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := sortMessage(t, md, "", "<a@x>", "", "Mon, 2 Feb 2015 10:00:00 +0000", "Cid <c@x>", "plan", "a longer body than the others")
+	b := sortMessage(t, md, "S", "<b@x>", "", "Mon, 2 Feb 2015 11:00:00 +0000", "ann <a@x>", "Re: agenda", "short")
+	c := sortMessage(t, md, "S", "<c@x>", "<a@x>", "Mon, 2 Feb 2015 12:00:00 +0000", "Bob <b@x>", "Re: plan", "medium body")
+
+	tests := []struct {
+		key     gomua.SortKey
+		reverse bool
+		want    []*gomua.Message
+	}{
+		{gomua.ByDate, false, []*gomua.Message{a, b, c}},
+		{gomua.ByDate, true, []*gomua.Message{c, b, a}},
+		{gomua.ByFrom, false, []*gomua.Message{b, c, a}},
+		{gomua.BySubject, false, []*gomua.Message{b, c, a}},
+		{gomua.BySize, false, []*gomua.Message{b, c, a}},
+		{gomua.BySize, true, []*gomua.Message{a, c, b}},
+		{gomua.ByUnread, false, []*gomua.Message{a, c, b}},
+		{gomua.ByUnread, true, []*gomua.Message{c, b, a}},
+		{gomua.ByThread, false, []*gomua.Message{b, a, c}},
+		{gomua.ByThread, true, []*gomua.Message{c, a, b}},
+	}
+	for _, tt := range tests {
+		msgs := []gomua.Mail{c, a, b}
+		gomua.Sort(msgs, tt.key, tt.reverse)
+		for i, m := range msgs {
+			if m != tt.want[i] {
+				t.Errorf("sort by %v (reverse %v): %s at %d, expected %s", tt.key, tt.reverse,
+					m.(*gomua.Message).Header.Get("Message-Id"), i, tt.want[i].Header.Get("Message-Id"))
+			}
+		}
+	}
+
+	for _, name := range []string{"date", "FROM", "thread"} {
+		if _, err := gomua.ParseSortKey(name); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := gomua.ParseSortKey("color"); err == nil {
+		t.Error("unknown sort order accepted")
+	}
+}
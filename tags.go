@@ -0,0 +1,170 @@
+package gomua
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/frenata/gomua/cache"
+)
+
+// A TagDB holds user tags, such as "work" or "follow-up", for Messages. Maildir flags only offer a fixed
+// set of letters, so tags are kept in a file of their own, keyed by Message-ID: they follow a Message
+// through flag changes and moves between folders, and are shared by its copies.
+//
+// The file is plain text, one Message per line: its Message-ID, then its tags, separated by spaces.
+// Tags are lower case, and hold no spaces.
+type TagDB struct {
+	path string
+
+	mu    sync.Mutex
+	tags  map[string][]string // sorted
+	dirty bool
+}
+
+// OpenTagDB reads the TagDB stored at path. If there is no file at path yet, an empty TagDB is returned
+// that Save will create.
+func OpenTagDB(path string) (*TagDB, error) {
+	db := &TagDB{path: path, tags: make(map[string][]string)}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		db.tags[fields[0]] = normalizeTags(fields[1:])
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("tags %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// Tags returns the tags of a Message, sorted.
+func (db *TagDB) Tags(m *Message) []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.tags[m.MessageID()]...)
+}
+
+// HasTag checks if a Message has a tag.
+func (db *TagDB) HasTag(m *Message, tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, t := range db.Tags(m) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Tag adds tags to a Message and removes others from it. It returns ErrNoMessageID if the Message has
+// no Message-ID, and an error naming any tag that is empty or holds a space.
+func (db *TagDB) Tag(m *Message, add, remove []string) error {
+	id := m.MessageID()
+	if id == "" {
+		return ErrNoMessageID
+	}
+	for _, t := range append(append([]string(nil), add...), remove...) {
+		if !validTag(t) {
+			return fmt.Errorf("invalid tag %q", t)
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	set := make(map[string]bool)
+	for _, t := range db.tags[id] {
+		set[t] = true
+	}
+	for _, t := range add {
+		set[strings.ToLower(t)] = true
+	}
+	for _, t := range remove {
+		delete(set, strings.ToLower(t))
+	}
+
+	var tags []string
+	for t := range set {
+		tags = append(tags, t)
+	}
+	if len(tags) == 0 {
+		delete(db.tags, id)
+	} else {
+		db.tags[id] = normalizeTags(tags)
+	}
+	db.dirty = true
+	return nil
+}
+
+// Save writes the TagDB back to its file if it has changed.
+func (db *TagDB) Save() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if !db.dirty {
+		return nil
+	}
+
+	ids := make([]string, 0, len(db.tags))
+	for id := range db.tags {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	err := cache.WriteFile(db.path, func(f io.Writer) error {
+		w := bufio.NewWriter(f)
+		for _, id := range ids {
+			fmt.Fprintf(w, "%s %s\n", id, strings.Join(db.tags[id], " "))
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	db.dirty = false
+	return nil
+}
+
+// validTag checks that a tag can be stored: it is not empty, and holds no spaces or control characters.
+func validTag(t string) bool {
+	if t == "" {
+		return false
+	}
+	for _, r := range t {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTags lower cases, sorts and dedupes tags.
+func normalizeTags(tags []string) []string {
+	set := make(map[string]bool)
+	var out []string
+	for _, t := range tags {
+		t = strings.ToLower(t)
+		if !set[t] {
+			set[t] = true
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,85 @@
+package gomua_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frenata/gomua"
+)
+
+func Test_TagDB(t *testing.T) {
+	root, err := ioutil.TempDir("", "gomua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	md := gomua.Maildir(root)
+	archive, err := md.CreateFolder("Archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := md.Store(strings.NewReader("Message-Id: <a@x>\r\nSubject: bill\r\n\r\nbody\r\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := gomua.Scan(path)[0].(*gomua.Message)
+	anon, _ := gomua.ReadMessage(strings.NewReader("Subject: no id\r\n\r\nbody\r\n"))
+
+	dbPath := filepath.Join(root, "tags")
+	db, err := gomua.OpenTagDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Tag(m, []string{"Work", "invoice", "follow-up"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Tag(m, nil, []string{"follow-up"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Tag(anon, []string{"work"}, nil); err != gomua.ErrNoMessageID {
+		t.Fatalf("tagging a message without Message-ID gave %v", err)
+	}
+	if err := db.Tag(m, []string{"two words"}, nil); err == nil {
+		t.Fatal("tag with a space accepted")
+	}
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// tags survive flag changes and moves
+	if err := m.Flag(gomua.Seen); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MoveTo(archive); err != nil {
+		t.Fatal(err)
+	}
+	m = gomua.Scan(m.Filename())[0].(*gomua.Message)
+
+	db, err = gomua.OpenTagDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := db.Tags(m); !reflect.DeepEqual(tags, []string{"invoice", "work"}) {
+		t.Fatalf("reopened tags are %q", tags)
+	}
+
+	q, err := gomua.ParseQuery("tag:WORK -tag:follow-up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Match(m) {
+		t.Error("tag: matched without a TagDB")
+	}
+	q.Tags = db
+	if !q.Match(m) {
+		t.Error("tag: did not match a tagged message")
+	}
+	if q.Match(anon) {
+		t.Error("tag: matched an untagged message")
+	}
+}
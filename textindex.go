@@ -0,0 +1,45 @@
+package gomua
+
+import (
+	"os"
+
+	"github.com/frenata/gomua/cache"
+)
+
+// IndexText brings a TextIndex up to date with the decoded text of the Messages in msgs, reading only
+// the files that are new or have changed since they were indexed; files that were just renamed, as
+// when their flags change, are not read again. Messages that were not read from a file are skipped.
+// It returns how many Messages were read, and the first error met, after indexing all it could.
+func IndexText(ix *cache.TextIndex, msgs []Mail) (int, error) {
+	var n int
+	var firstErr error
+	index := func(m *Message) {
+		if m.filename == "" {
+			return
+		}
+		fi, err := os.Stat(m.filename)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		if ix.Current(m.filename, fi) {
+			return
+		}
+		ix.Add(m.filename, fi, m.SanitizeContent())
+		n++
+	}
+
+	for _, m := range msgs {
+		switch m := m.(type) {
+		case *Message:
+			index(m)
+		case *MessageThread:
+			for _, msg := range m.Messages() {
+				index(msg)
+			}
+		}
+	}
+	return n, firstErr
+}
@@ -2,83 +2,206 @@ package gomua
 
 import (
 	"fmt"
-	"strings"
+	"sort"
+	"time"
 )
 
-// MessageThread is a linked list of Messages
+// MessageThread is a tree of Messages, each reply a child of the Message it replies to.
 type MessageThread struct {
-	head *ThreadNode
+	root *ThreadNode
 }
 
-// A ThreadNode is one element of the MessageThread linked list.
+// A ThreadNode is one Message of a MessageThread, along with its place in the thread.
+// The root of a thread has no Message if the Message its replies share is missing.
 type ThreadNode struct {
-	msg  *Message
-	next *ThreadNode
+	msg      *Message
+	parent   *ThreadNode
+	children []*ThreadNode
+}
+
+// Message returns the Message of the node, or nil if it is missing.
+func (n *ThreadNode) Message() *Message { return n.msg }
+
+// Parent returns the node this node's Message replies to, or nil for the root.
+func (n *ThreadNode) Parent() *ThreadNode { return n.parent }
+
+// Children returns the replies to this node's Message, oldest first.
+func (n *ThreadNode) Children() []*ThreadNode { return n.children }
+
+// Depth returns how far below the root of its thread the node is. The root has depth 0.
+func (n *ThreadNode) Depth() int {
+	depth := 0
+	for p := n.parent; p != nil; p = p.parent {
+		depth++
+	}
+	return depth
+}
+
+// walk calls fn for n and every node below it, depth first with siblings in order.
+func (n *ThreadNode) walk(fn func(*ThreadNode)) {
+	fn(n)
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+}
+
+// date returns the date of the node's Message, or for a missing Message the date of its oldest reply.
+func (n *ThreadNode) date() time.Time {
+	if n.msg != nil {
+		return n.msg.Date()
+	}
+	var oldest time.Time
+	for _, child := range n.children {
+		if d := child.date(); !d.IsZero() && (oldest.IsZero() || d.Before(oldest)) {
+			oldest = d
+		}
+	}
+	return oldest
+}
+
+// sortChildren orders the replies below n chronologically. Replies with no date come last.
+func (n *ThreadNode) sortChildren() {
+	sort.SliceStable(n.children, func(i, j int) bool {
+		di, dj := n.children[i].date(), n.children[j].date()
+		if di.IsZero() || dj.IsZero() {
+			return !di.IsZero() && dj.IsZero()
+		}
+		return di.Before(dj)
+	})
+	for _, child := range n.children {
+		child.sortChildren()
+	}
+}
+
+// Root returns the root node of the thread.
+func (t *MessageThread) Root() *ThreadNode { return t.root }
+
+// Messages returns the Messages of the thread in reading order: depth first, replies after what they reply to.
+func (t *MessageThread) Messages() []*Message {
+	var msgs []*Message
+	if t.root == nil {
+		return msgs
+	}
+	t.root.walk(func(n *ThreadNode) {
+		if n.msg != nil {
+			msgs = append(msgs, n.msg)
+		}
+	})
+	return msgs
+}
+
+// Len returns the number of Messages in the thread.
+func (t *MessageThread) Len() int {
+	return len(t.Messages())
+}
+
+// Unread returns the number of unread Messages in the thread.
+func (t *MessageThread) Unread() int {
+	n := 0
+	for _, msg := range t.Messages() {
+		if msg.Unread() {
+			n++
+		}
+	}
+	return n
+}
+
+// Latest returns the date of the newest Message in the thread: when it last saw activity.
+// It is the zero time if no Message has a date.
+func (t *MessageThread) Latest() time.Time {
+	var latest time.Time
+	for _, msg := range t.Messages() {
+		if d := msg.Date(); d.After(latest) {
+			latest = d
+		}
+	}
+	return latest
+}
+
+// first returns the first Message of the thread in reading order, or nil if it has none.
+func (t *MessageThread) first() *Message {
+	if msgs := t.Messages(); len(msgs) > 0 {
+		return msgs[0]
+	}
+	return nil
 }
 
 //  Display a single message
 func (t *MessageThread) String() string {
-	node := t.head
+	msg := t.first()
+	if msg == nil {
+		return "No message."
+	}
 	var output string
-	output = fmt.Sprintf("From: %v\n", node.msg.Header.Get("From")) +
-		fmt.Sprintf("To: %v\n", node.msg.Header.Get("To")) +
-		fmt.Sprintf("Date: %v\n", node.msg.Header.Get("Date")) +
-		fmt.Sprintf("Subject: %v\n", node.msg.Header.Get("Subject")) +
-		fmt.Sprintf("\n%s\n", node.msg.Content())
+	output = fmt.Sprintf("From: %v\n", msg.From()) +
+		fmt.Sprintf("To: %v\n", msg.To()) +
+		fmt.Sprintf("Date: %v\n", msg.DecodedHeader("Date")) +
+		fmt.Sprintf("Subject: %v\n", msg.Subject()) +
+		fmt.Sprintf("\n%s\n", msg.SanitizeContent())
 
 	return output
 }
 
-// Summary returns a subject - from summary of the messages in this thread
+// Summary returns a subject - from summary of each message in this thread, drawn as a tree of replies:
+//     Plans from Ann
+//     ├─ Re: Plans from Bob
+//     │  └─ Re: Plans from Ann
+//     └─ Re: Plans from Cid
 func (t *MessageThread) Summary() string {
-	if t.head == nil {
+	if t.first() == nil {
 		return "No message."
 	}
+	return summaryTree(t.root, "", "")
+}
 
-	node := t.head
+// summaryTree draws n and the nodes below it, starting its own line with lead and those of its replies with indent.
+func summaryTree(n *ThreadNode, lead, indent string) string {
 	var output string
-	subject := node.msg.Header.Get("Subject")
-	from := node.msg.Header.Get("From")
-	output += fmt.Sprintf("%s from %s", color(subject, "31"), color(from, "33"))
-
-	for node.next != nil {
-		node = node.next
-		subject := node.msg.Header.Get("Subject")
-		from := node.msg.Header.Get("From")
-		output += fmt.Sprintf("\n\t%s from %s", color(subject, "31"), color(from, "33"))
+	if n.msg != nil {
+		output = lead + fmt.Sprintf("%s from %s", color(n.msg.Subject(), "31"), color(n.msg.From(), "33"))
+	} else {
+		output = lead + color("(missing message)", "90")
 	}
-	return output
-}
 
-func (t *MessageThread) appendNode(n *ThreadNode) {
-	node := t.head
-	for node.next != nil {
-		node = node.next
+	for i, child := range n.children {
+		branch, next := "├─ ", "│  "
+		if i == len(n.children)-1 {
+			branch, next = "└─ ", "   "
+		}
+		output += "\n" + summaryTree(child, indent+branch, indent+next)
 	}
-	node.next = n
+	return output
 }
 
-// Thread takes a Mail slice and returns a map of subjects to Threads.
+// Thread takes a Mail slice and groups the Messages in it into threads, using the algorithm
+// described at https://www.jwz.org/doc/threading.html: replies are linked to their parents through
+// their References and In-Reply-To headers, and threads whose roots are missing or whose references
+// were lost are joined by subject.
+// The returned map is keyed by the Message-ID of the root of each thread. Threads whose root message
+// is missing are keyed by its Message-ID if it is known, or otherwise by "subject:" and their subject.
+// MessageThreads in msgs are split into their Messages and threaded again, any other Mail is ignored.
 func Thread(msgs []Mail) map[string]*MessageThread {
-	threads := make(map[string]*MessageThread)
-
+	th := newThreader()
 	for _, m := range msgs {
-		node := new(ThreadNode)
-		node.msg = m.(*Message)
-		refs := node.msg.Header.Get("References")
-		refSlice := strings.Split(refs, " ")
-		parent := refSlice[0]
-		if len(parent) == 0 {
-			parent = node.msg.Header.Get("Message-Id")
-		}
-		if threads[parent] == nil {
-			thread := new(MessageThread)
-			thread.head = node
-			threads[parent] = thread
-		} else {
-			existingThread := threads[parent]
-			existingThread.appendNode(node)
+		switch m := m.(type) {
+		case *Message:
+			th.add(m)
+		case *MessageThread:
+			for _, msg := range m.Messages() {
+				th.add(msg)
+			}
 		}
 	}
+
+	roots := th.roots()
+	roots = pruneEmpty(roots, nil)
+	roots = groupBySubject(roots)
+
+	threads := make(map[string]*MessageThread)
+	for _, root := range roots {
+		thread := &MessageThread{root: root.node(nil)}
+		thread.root.sortChildren()
+		threads[root.key()] = thread
+	}
 	return threads
 }
@@ -10,8 +10,8 @@ func TestGetMessages(t *testing.T) {
 	msgs := Scan(dir)
 	threads := Thread(msgs)
 
-	if len(threads) != 26 {
-		t.Errorf("Incorrect number of threads, expected %v got %v", 26, len(threads))
+	if len(threads) != 22 {
+		t.Errorf("Incorrect number of threads, expected %v got %v", 22, len(threads))
 	}
 
 	wantSubject := "Hannover BSD meetup"
@@ -20,3 +20,101 @@ func TestGetMessages(t *testing.T) {
 		t.Errorf("Could not find thread with subject %v, found %v instead.", wantSubject, msg.Summary())
 	}
 }
+
+func threadMessage(t *testing.T, headers string) *Message {
+	msg, err := ReadMessage(strings.NewReader(headers + "\r\n\r\nbody\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestThreadJWZ(t *testing.T) {
+	root := threadMessage(t, "Message-Id: <a@x>\r\nSubject: plan")
+	reply := threadMessage(t, "Message-Id: <b@x>\r\nIn-Reply-To: <a@x>\r\nSubject: Re: plan")
+	// replies to a message that was never received
+	orphan1 := threadMessage(t, "Message-Id: <c@x>\r\nReferences: <a@x> <gone@x>\r\nSubject: Re: plan")
+	orphan2 := threadMessage(t, "Message-Id: <d@x>\r\nReferences: <lost@x>\r\nSubject: Re: other")
+	orphan3 := threadMessage(t, "Message-Id: <e@x>\r\nReferences: <lost@x>\r\nSubject: Re: other")
+	// the references were dropped, only the subject is left
+	stripped := threadMessage(t, "Message-Id: <f@x>\r\nSubject: RE: Plan")
+	// a loop between references
+	loop1 := threadMessage(t, "Message-Id: <g@x>\r\nReferences: <h@x>\r\nSubject: loop")
+	loop2 := threadMessage(t, "Message-Id: <h@x>\r\nReferences: <g@x>\r\nSubject: loop")
+
+	msgs := []Mail{orphan1, stripped, reply, root, orphan2, orphan3, loop1, loop2, nil}
+	threads := Thread(msgs)
+
+	if len(threads) != 3 {
+		t.Fatalf("expected 3 threads, got %d: %v", len(threads), threads)
+	}
+	if n := threads["<a@x>"].Len(); n != 4 {
+		t.Errorf("thread <a@x> holds %d messages, expected 4", n)
+	}
+	if threads["<a@x>"].Root().Message() != root {
+		t.Errorf("thread <a@x> does not start with its root")
+	}
+	if n := threads["<lost@x>"].Len(); n != 2 {
+		t.Errorf("thread <lost@x> holds %d messages, expected 2", n)
+	}
+	if n := threads["<h@x>"].Len(); n != 2 {
+		t.Errorf("looping thread holds %d messages, expected 2", n)
+	}
+
+	// threads can be threaded again
+	again := Thread([]Mail{threads["<a@x>"], threads["<lost@x>"]})
+	if len(again) != 2 {
+		t.Errorf("rethreading gave %d threads, expected 2", len(again))
+	}
+}
+
+func TestThreadTree(t *testing.T) {
+	root := threadMessage(t, "Message-Id: <a@x>\r\nDate: Mon, 2 Feb 2015 10:00:00 +0000\r\nSubject: plan\r\nFrom: ann")
+	late := threadMessage(t, "Message-Id: <b@x>\r\nDate: Mon, 2 Feb 2015 12:00:00 +0000\r\nIn-Reply-To: <a@x>\r\nSubject: Re: plan\r\nFrom: bob")
+	early := threadMessage(t, "Message-Id: <c@x>\r\nDate: Mon, 2 Feb 2015 11:00:00 +0000\r\nIn-Reply-To: <a@x>\r\nSubject: Re: plan\r\nFrom: cid")
+	deep := threadMessage(t, "Message-Id: <d@x>\r\nDate: Mon, 2 Feb 2015 13:00:00 +0000\r\nReferences: <a@x> <c@x>\r\nSubject: Re: plan\r\nFrom: ann")
+	undated := threadMessage(t, "Message-Id: <e@x>\r\nIn-Reply-To: <a@x>\r\nSubject: Re: plan\r\nFrom: dee")
+
+	thread := Thread([]Mail{deep, undated, late, root, early})["<a@x>"]
+	if thread == nil {
+		t.Fatal("no thread for <a@x>")
+	}
+
+	top := thread.Root()
+	if top.Message() != root || top.Parent() != nil || top.Depth() != 0 {
+		t.Fatal("root node does not hold the root message")
+	}
+	kids := top.Children()
+	if len(kids) != 3 || kids[0].Message() != early || kids[1].Message() != late || kids[2].Message() != undated {
+		t.Fatal("replies are not in chronological order, undated last")
+	}
+	grandkids := kids[0].Children()
+	if len(grandkids) != 1 || grandkids[0].Message() != deep || grandkids[0].Depth() != 2 || grandkids[0].Parent() != kids[0] {
+		t.Fatal("reply to a reply is not its child")
+	}
+
+	want := []*Message{root, early, deep, late, undated}
+	for i, msg := range thread.Messages() {
+		if msg != want[i] {
+			t.Fatalf("message %d out of reading order", i)
+		}
+	}
+
+	if thread.Len() != 5 || thread.Unread() != 5 {
+		t.Errorf("thread holds %d messages, %d unread, expected 5 and 5", thread.Len(), thread.Unread())
+	}
+	if latest := thread.Latest(); latest.Hour() != 13 {
+		t.Errorf("thread last active at %v, expected the date of its newest reply", latest)
+	}
+
+	lines := strings.Split(thread.Summary(), "\n")
+	prefixes := []string{"", "├─ ", "│  └─ ", "├─ ", "└─ "}
+	if len(lines) != len(prefixes) {
+		t.Fatalf("summary has %d lines, expected %d:\n%s", len(lines), len(prefixes), thread.Summary())
+	}
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(lines[i], prefix+"\033[31m") {
+			t.Errorf("summary line %d is %q, expected it to start with %q", i, lines[i], prefix)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package words
+
+import "strings"
+
+// StemCut is the most letters Stem cuts from the end of a word, counting the last letter of the stem,
+// which it may have changed: the stem without its last letter always begins the word.
+const StemCut = 6
+
+// Stem reduces an English word to its stem with the first step of the Porter stemming algorithm, which
+// removes plurals and -ed and -ing endings: "meetings" and "meeting" both become "meet", "ponies" "poni".
+// Words that are not lower case ASCII letters are returned as they are.
+func Stem(w string) string {
+	for i := 0; i < len(w); i++ {
+		if w[i] < 'a' || w[i] > 'z' {
+			return w
+		}
+	}
+	if len(w) <= 2 {
+		return w
+	}
+
+	// step 1a: plurals
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ies"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ss"):
+	case strings.HasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+
+	// step 1b: -eed, -ed and -ing
+	trimmed := false
+	switch {
+	case strings.HasSuffix(w, "eed"):
+		if measure(w[:len(w)-3]) > 0 {
+			w = w[:len(w)-1]
+		}
+	case strings.HasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		w, trimmed = w[:len(w)-2], true
+	case strings.HasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		w, trimmed = w[:len(w)-3], true
+	}
+	if trimmed {
+		switch {
+		case strings.HasSuffix(w, "at"), strings.HasSuffix(w, "bl"), strings.HasSuffix(w, "iz"):
+			w += "e"
+		case doubleConsonant(w) && !strings.HasSuffix(w, "l") && !strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "z"):
+			w = w[:len(w)-1]
+		case measure(w) == 1 && cvc(w):
+			w += "e"
+		}
+	}
+
+	// step 1c: a final y after a vowel becomes i
+	if strings.HasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		w = w[:len(w)-1] + "i"
+	}
+	return w
+}
+
+// consonant checks if the letter at i of w is a consonant. A y is one unless it follows a consonant.
+func consonant(w string, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !consonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w, the m of the Porter algorithm.
+func measure(w string) int {
+	m := 0
+	vowel := false
+	for i := range w {
+		if !consonant(w, i) {
+			vowel = true
+		} else if vowel {
+			m++
+			vowel = false
+		}
+	}
+	return m
+}
+
+// hasVowel checks if w has a vowel.
+func hasVowel(w string) bool {
+	for i := range w {
+		if !consonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant checks if w ends with the same consonant twice.
+func doubleConsonant(w string) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && consonant(w, n-1)
+}
+
+// cvc checks if w ends consonant-vowel-consonant, the last not w, x or y, as in "hop".
+func cvc(w string) bool {
+	n := len(w)
+	if n < 3 || !consonant(w, n-3) || consonant(w, n-2) || !consonant(w, n-1) {
+		return false
+	}
+	c := w[n-1]
+	return c != 'w' && c != 'x' && c != 'y'
+}
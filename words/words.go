@@ -0,0 +1,25 @@
+// Package words splits text into words and reduces them to their stems, for full-text search.
+package words
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The shortest word split from text.
+const minWordLen = 2
+
+// Split splits text into lower case words: runs of letters and digits, joined across apostrophes,
+// leaving out single letters.
+func Split(text string) []string {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' }
+
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isWord(r) }) {
+		w = strings.Trim(w, "'")
+		if len([]rune(w)) >= minWordLen {
+			words = append(words, w)
+		}
+	}
+	return words
+}
@@ -0,0 +1,44 @@
+package words
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	got := Split("Don't re-send the MEETING notes, Zoë! a 42x aGVsbG8gd29ybGQgdGhpcyBpcyBhIGxvbmcgYmFzZTY0IHN0cmluZw")
+	want := []string{"don't", "re", "send", "the", "meeting", "notes", "zoë", "42x",
+		"agvsbg8gd29ybgqgdghpcybpcybhigxvbmcgymfzzty0ihn0cmluzw"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split = %q, expected %q", got, want)
+	}
+}
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"meetings": "meet",
+		"meeting":  "meet",
+		"caresses": "caress",
+		"ponies":   "poni",
+		"cats":     "cat",
+		"agreed":   "agree",
+		"hopping":  "hop",
+		"hoping":   "hope",
+		"filing":   "file",
+		"falling":  "fall",
+		"happy":    "happi",
+		"sky":      "sky",
+		"is":       "is",
+		"zoë":      "zoë",
+		"sittings": "sit",
+	} {
+		got := Stem(word)
+		if got != want {
+			t.Errorf("Stem(%s) = %s, expected %s", word, got, want)
+		}
+		if start := got[:len(got)-1]; !strings.HasPrefix(word, start) || len(word)-len(start) > StemCut {
+			t.Errorf("Stem(%s) = %s cuts more than StemCut letters", word, got)
+		}
+	}
+}